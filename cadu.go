@@ -0,0 +1,142 @@
+package cadus
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	CaduLen       = 1024
+	CaduHeaderLen = 14
+	CaduCRCLen    = 2
+	CaduBodyLen   = CaduLen - CaduHeaderLen - CaduCRCLen
+)
+
+const MaxSequenceCounter = uint32(1 << 24)
+
+var CaduMagic = []byte{0x1a, 0xcf, 0xfc, 0x1d}
+
+const (
+	PointerMask    = 0x07FF
+	PointerNoStart = 0x07FF
+	PointerIdle    = 0x07FE
+)
+
+type ChecksumError struct {
+	Want uint16
+	Got  uint16
+}
+
+func (c ChecksumError) Error() string {
+	return fmt.Sprintf("invalid checksum: want %04x, got %04x", c.Want, c.Got)
+}
+
+type Header struct {
+	Word      uint32
+	Version   uint8
+	Space     uint16
+	Channel   uint8
+	Master    uint8
+	Sequence  uint32
+	Replay    bool
+	Signaling uint8
+	Control   uint16
+	Data      uint16
+}
+
+func (h *Header) Pointer() uint16 {
+	return h.Data & PointerMask
+}
+
+func (h *Header) PointerString() string {
+	switch p := h.Pointer(); p {
+	case PointerNoStart:
+		return "nostart"
+	case PointerIdle:
+		return "idle"
+	default:
+		return fmt.Sprintf("%04x", p)
+	}
+}
+
+func (h *Header) Key() ChannelKey {
+	return ChannelKey{Space: h.Space, Channel: h.Channel, Replay: h.Replay}
+}
+
+type ChannelKey struct {
+	Space   uint16
+	Channel uint8
+	Replay  bool
+}
+
+func (k ChannelKey) String() string {
+	mode := "rt"
+	if k.Replay {
+		mode = "pb"
+	}
+	return fmt.Sprintf("%d/%d(%s)", k.Space, k.Channel, mode)
+}
+
+func SortKeys(keys []ChannelKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Space != keys[j].Space {
+			return keys[i].Space < keys[j].Space
+		}
+		if keys[i].Channel != keys[j].Channel {
+			return keys[i].Channel < keys[j].Channel
+		}
+		return !keys[i].Replay && keys[j].Replay
+	})
+}
+
+type Cadu struct {
+	*Header
+	Insert    []byte
+	Secondary []byte
+	Payload   []byte
+	OCF       uint32
+	Control   uint16
+	Check     []byte
+	Error     error
+
+	profile Profile
+	buffer  *caduBuffer
+}
+
+func (c *Cadu) Missing(p *Cadu) uint32 {
+	if p == nil {
+		return 0
+	}
+	mask := c.Profile().CounterMask()
+	if delta := (c.Sequence - p.Sequence) & mask; delta > 1 && delta <= mask/2 {
+		return delta - 1
+	}
+	return 0
+}
+
+func (c *Cadu) Backward(p *Cadu) uint32 {
+	if p == nil {
+		return 0
+	}
+	mask := c.Profile().CounterMask()
+	if delta := (c.Sequence - p.Sequence) & mask; delta > mask/2 {
+		return (p.Sequence - c.Sequence) & mask
+	}
+	return 0
+}
+
+func (c *Cadu) Profile() Profile {
+	if c.profile.Length == 0 {
+		return DefaultProfile
+	}
+	return c.profile
+}
+
+func (c *Cadu) Bytes() []byte {
+	return c.Profile().Encode(c)
+}
+
+func DecodeCadu(r io.Reader) (*Cadu, error) {
+	return DefaultProfile.Decode(r)
+}
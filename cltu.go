@@ -0,0 +1,131 @@
+package cadus
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	CodeblockLen  = 8
+	CodeblockInfo = CodeblockLen - 1
+)
+
+var (
+	CLTUStart = []byte{0xeb, 0x90}
+	CLTUTail  = []byte{0xc5, 0xc5, 0xc5, 0xc5, 0xc5, 0xc5, 0xc5, 0x79}
+	CLTUFill  = byte(0x55)
+)
+
+type CodeblockError struct {
+	Block int
+	Want  uint8
+	Got   uint8
+}
+
+func (c CodeblockError) Error() string {
+	return fmt.Sprintf("invalid codeblock %d: want %02x, got %02x", c.Block, c.Want, c.Got)
+}
+
+type CLTU struct {
+	Data      []byte
+	Blocks    int
+	Bad       int
+	Corrected int
+	Error     error
+}
+
+func EncodeCLTU(bs []byte) []byte {
+	n := (len(bs) + CodeblockInfo - 1) / CodeblockInfo
+
+	var body bytes.Buffer
+	body.Grow(len(CLTUStart) + n*CodeblockLen + len(CLTUTail))
+	body.Write(CLTUStart)
+	for i := 0; i < n; i++ {
+		block := bytes.Repeat([]byte{CLTUFill}, CodeblockInfo)
+		copy(block, bs[i*CodeblockInfo:])
+		body.Write(block)
+		body.WriteByte(BCH(block))
+	}
+	body.Write(CLTUTail)
+	return body.Bytes()
+}
+
+func DecodeCLTU(r *bufio.Reader, correct bool) (*CLTU, error) {
+	if err := syncCLTU(r); err != nil {
+		return nil, err
+	}
+	var (
+		c     CLTU
+		block = make([]byte, CodeblockLen)
+	)
+	for {
+		if _, err := io.ReadFull(r, block); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if bytes.Equal(block, CLTUTail) {
+			break
+		}
+		switch n, err := VerifyCodeblock(block, correct); {
+		case err != nil:
+			if c.Bad++; c.Error == nil {
+				e := err.(CodeblockError)
+				e.Block = c.Blocks
+				c.Error = e
+			}
+		case n > 0:
+			c.Corrected++
+		}
+		c.Blocks++
+		c.Data = append(c.Data, block[:CodeblockInfo]...)
+	}
+	return &c, nil
+}
+
+func syncCLTU(r *bufio.Reader) error {
+	var prev byte
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if i > 0 && prev == CLTUStart[0] && b == CLTUStart[1] {
+			return nil
+		}
+		prev = b
+	}
+}
+
+func VerifyCodeblock(block []byte, correct bool) (int, error) {
+	want, got := BCH(block[:CodeblockInfo]), block[CodeblockInfo]
+	if (want^got)&0xFE == 0 {
+		return 0, nil
+	}
+	if correct {
+		for i := 0; i < (CodeblockLen*8)-1; i++ {
+			block[i/8] ^= 0x80 >> uint(i%8)
+			if (BCH(block[:CodeblockInfo])^block[CodeblockInfo])&0xFE == 0 {
+				return 1, nil
+			}
+			block[i/8] ^= 0x80 >> uint(i%8)
+		}
+	}
+	return 0, CodeblockError{Want: want, Got: got}
+}
+
+func BCH(bs []byte) uint8 {
+	var rem uint8
+	for _, b := range bs {
+		for i := 7; i >= 0; i-- {
+			fb := (b>>uint(i))&1 ^ (rem>>6)&1
+			if rem = (rem << 1) & 0x7F; fb == 1 {
+				rem ^= 0x45
+			}
+		}
+	}
+	return (^rem & 0x7F) << 1
+}
@@ -0,0 +1,65 @@
+package cadus
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestVerifyCodeblock(t *testing.T) {
+	info := []byte("codebl!")
+	data := []struct {
+		Name    string
+		Flip    int
+		Correct bool
+		Count   int
+		Invalid bool
+	}{
+		{Name: "valid", Flip: -1},
+		{Name: "filler", Flip: 63},
+		{Name: "detected", Flip: 10, Invalid: true},
+		{Name: "corrected", Flip: 10, Correct: true, Count: 1},
+		{Name: "parity", Flip: 58, Correct: true, Count: 1},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			block := append(append([]byte{}, info...), BCH(info))
+			if d.Flip >= 0 {
+				block[d.Flip/8] ^= 0x80 >> uint(d.Flip%8)
+			}
+			n, err := VerifyCodeblock(block, d.Correct)
+			if d.Invalid {
+				if _, ok := err.(CodeblockError); !ok {
+					t.Fatalf("want codeblock error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if n != d.Count {
+				t.Errorf("want %d bits corrected, got %d", d.Count, n)
+			}
+			if !bytes.Equal(block[:CodeblockInfo], info) {
+				t.Errorf("want %x, got %x", info, block[:CodeblockInfo])
+			}
+		})
+	}
+}
+
+func TestCLTURoundTrip(t *testing.T) {
+	data := []byte("a telecommand longer than a single codeblock")
+
+	bs := EncodeCLTU(data)
+	bs[len(CLTUStart)+CodeblockLen+2] ^= 0x10
+	c, err := DecodeCLTU(bufio.NewReader(bytes.NewReader(append([]byte{0x00, 0xeb}, bs...))), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := (len(data) + CodeblockInfo - 1) / CodeblockInfo; c.Blocks != want || c.Corrected != 1 || c.Bad != 0 {
+		t.Errorf("want %d blocks (1 corrected), got %d (%d corrected, %d bad)", want, c.Blocks, c.Corrected, c.Bad)
+	}
+	if !bytes.HasPrefix(c.Data, data) || len(bytes.Trim(c.Data[len(data):], string(CLTUFill))) > 0 {
+		t.Errorf("data mismatched: want %q, got %q", data, c.Data)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type threshold struct {
+	Limit   float64
+	Percent bool
+	given   bool
+}
+
+func (t *threshold) String() string {
+	s := strconv.FormatFloat(t.Limit, 'f', -1, 64)
+	if t.Percent {
+		s += "%"
+	}
+	return s
+}
+
+func (t *threshold) Set(str string) error {
+	percent := strings.HasSuffix(str, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+	if err != nil {
+		return err
+	}
+	if v < 0 || (percent && v > 100) {
+		return fmt.Errorf("%s: threshold out of range", str)
+	}
+	t.Limit, t.Percent, t.given = v, percent, true
+	return nil
+}
+
+func (t threshold) Exceeded(n, total int) bool {
+	if !t.Percent {
+		return float64(n) > t.Limit
+	}
+	if total == 0 {
+		return false
+	}
+	return float64(n)*100/float64(total) > t.Limit
+}
+
+type checker struct {
+	Missing   threshold
+	Corrupted threshold
+	URL       string
+}
+
+func checkFlags(set *flag.FlagSet) *checker {
+	var c checker
+	set.Var(&c.Missing, "max-missing", "missing cadus tolerated, as a count or a percentage of the expected cadus (e.g. 0.1%), before exiting non-zero")
+	set.Var(&c.Corrupted, "max-crc", "corrupted cadus tolerated, as a count or a percentage of the received cadus, before exiting non-zero")
+	set.StringVar(&c.URL, "alarm-url", "", "url that a json alarm is posted to when a threshold is exceeded")
+	return &c
+}
+
+func (c *checker) enabled(quiet bool) bool {
+	return quiet || c.Missing.given || c.Corrupted.given || c.URL != ""
+}
+
+func (c *checker) Check(s Summary, inputs []string) int {
+	var (
+		code    int
+		reasons []string
+		z       = s.Total()
+	)
+	if c.Missing.Exceeded(int(z.Missing), z.Expected()) {
+		code |= ExitMissing
+		reasons = append(reasons, fmt.Sprintf("%d missing cadus (%.4f%%) over %s tolerated", z.Missing, z.Loss(), &c.Missing))
+	}
+	if c.Corrupted.Exceeded(z.Corrupted, z.Count) {
+		code |= ExitCorrupted
+		reasons = append(reasons, fmt.Sprintf("%d corrupted cadus (%.4f%%) over %s tolerated", z.Corrupted, z.FER(), &c.Corrupted))
+	}
+	for _, r := range reasons {
+		log.Printf("[alarm] %s", r)
+	}
+	if code != 0 && c.URL != "" {
+		c.post(z, inputs, reasons)
+	}
+	return code
+}
+
+func (c *checker) post(z Stats, inputs []string, reasons []string) {
+	body, _ := json.Marshal(struct {
+		Kind    string    `json:"kind"`
+		When    time.Time `json:"time"`
+		Inputs  []string  `json:"inputs"`
+		Reasons []string  `json:"reasons"`
+		Quality quality   `json:"quality"`
+	}{"threshold", time.Now().UTC(), inputs, reasons, z.Quality()})
+	rs, err := http.Post(c.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[alarm] %s: %s", c.URL, err)
+		return
+	}
+	rs.Body.Close()
+	if rs.StatusCode >= http.StatusBadRequest {
+		log.Printf("[alarm] %s: %s", c.URL, rs.Status)
+	}
+}
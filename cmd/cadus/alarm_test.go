@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestThreshold(t *testing.T) {
+	data := []struct {
+		Input   string
+		Limit   float64
+		Percent bool
+		Err     bool
+	}{
+		{Input: "10", Limit: 10},
+		{Input: "0.1%", Limit: 0.1, Percent: true},
+		{Input: "100%", Limit: 100, Percent: true},
+		{Input: "101%", Err: true},
+		{Input: "-1", Err: true},
+		{Input: "ten", Err: true},
+	}
+	for _, d := range data {
+		t.Run(d.Input, func(t *testing.T) {
+			var h threshold
+			err := h.Set(d.Input)
+			if d.Err {
+				if err == nil {
+					t.Fatalf("threshold %s accepted", d.Input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if h.Limit != d.Limit || h.Percent != d.Percent || !h.given {
+				t.Errorf("threshold mismatched: want %f (percent: %t), got %f (percent: %t)", d.Limit, d.Percent, h.Limit, h.Percent)
+			}
+			if h.String() != d.Input {
+				t.Errorf("threshold printed as %s, want %s", h.String(), d.Input)
+			}
+		})
+	}
+}
+
+func TestThresholdExceeded(t *testing.T) {
+	data := []struct {
+		Name      string
+		Threshold threshold
+		N, Total  int
+		Want      bool
+	}{
+		{Name: "count", Threshold: threshold{Limit: 10}, N: 10, Total: 100},
+		{Name: "count+over", Threshold: threshold{Limit: 10}, N: 11, Total: 100, Want: true},
+		{Name: "percent", Threshold: threshold{Limit: 1, Percent: true}, N: 10, Total: 1000},
+		{Name: "percent+over", Threshold: threshold{Limit: 1, Percent: true}, N: 11, Total: 1000, Want: true},
+		{Name: "percent+empty", Threshold: threshold{Limit: 0, Percent: true}, N: 0, Total: 0},
+		{Name: "zero", Threshold: threshold{}, N: 1, Total: 1, Want: true},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			if got := d.Threshold.Exceeded(d.N, d.Total); got != d.Want {
+				t.Errorf("%d/%d over %s: want %t, got %t", d.N, d.Total, &d.Threshold, d.Want, got)
+			}
+		})
+	}
+}
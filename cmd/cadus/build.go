@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+const VMURowPattern = "%10d | %08x | %8d | %d | %02x | %8d | %s | %t | %t"
+
+type LengthError struct {
+	Want int
+	Got  int
+}
+
+func (e LengthError) Error() string {
+	return fmt.Sprintf("invalid length: want %d, got %d", e.Want, e.Got)
+}
+
+var (
+	Hadock  = 0
+	Version = 2
+	Mode    = 255
+
+	MaxBuffer = 64 << 20
+)
+
+func runBuild(args []string) {
+	set := flag.NewFlagSet("build", flag.ExitOnError)
+	set.IntVar(&Hadock, "k", Hadock, "hadock version")
+	set.IntVar(&Version, "u", Version, "VMU version")
+	set.IntVar(&Mode, "m", Mode, "mode")
+	set.IntVar(&MaxBuffer, "max-buffer", MaxBuffer, "maximum bytes buffered while reassembling a frame before dropping them (0: no limit)")
+	clockFlags(set)
+	proto := set.String("p", "udp", "protocol")
+	hrdfe := set.Bool("hrdfe", false, "skip byte")
+	health := set.String("health", "", "serve health report on address")
+	stale := set.Duration("health-timeout", 10*time.Second, "maximum interval without cadus before reporting unhealthy")
+	logLimit := set.Int("log-limit", DefaultLogLimit, "identical error lines logged per interval before aggregating (0: no limit)")
+	logEvery := set.Duration("log-every", DefaultLogEvery, "interval over which identical error lines are aggregated")
+	alerts := alertFlags(set)
+	profileFlags(set)
+	scanFlags(set)
+	keyFlags(set)
+	set.StringVar(&NameTime, "time-from-name", NameTime, "strftime pattern of the capture start time in file names, spanning directories when it contains / (e.g. %Y%m%d_%H%M%S, rt: rolling rt archives)")
+	set.Var(&NameRate, "time-from-name-rate", "nominal bitrate of the files, spacing the times of their cadus (e.g. 50Mbps)")
+	fopts := filterFlags(set)
+	joinFlags(set, "")
+	recordFlags(set)
+	hookFlags(set)
+	clientFlags(set)
+	compressFlags(set)
+	forward := set.String("forward", "", "forward reassembled frames with hadock framing to address (default scheme tcp)")
+	args = parseArgs(set, args)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	st, err := newTracker()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	queue, errc, err := openSource(ctx, *proto, args, *hrdfe)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	hc := NewHealth(*stale)
+	alerts.Health = hc
+	queue = alerts.Watch(ctx, fopts.Filter(ctx, queue))
+	failures := watchErrors(errc, hc)
+	if *health != "" {
+		queue = hc.Watch(ctx, queue)
+		serveHealth(*health, hc)
+	}
+	logger := log.New(os.Stderr, "[main] ", 0)
+	errs := newRateLogger(logger, *logLimit, *logEvery)
+
+	var fw io.Writer
+	if *forward != "" {
+		d, err := parseDestination(*forward, "tcp", multicast{})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		c, err := dial(d.Scheme, d.Addr, d.Multicast)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer c.Close()
+		fw = hc.Writer(*forward, c)
+	}
+
+	var count, partial, holed, invalid, dropped int
+	for f := range reassembleCadus(ctx, st.Track(ctx, queue), MaxBuffer, &dropped) {
+		vs := f.Data
+		if f.Partial {
+			partial++
+			logger.Printf("partial frame (%d bytes)", len(vs))
+		}
+		if n := f.Missing(); n > 0 {
+			holed++
+			errs.Printf("missing", "frame with %d bytes missing in %d holes (%d bytes received)", n, len(f.Holes), len(vs))
+			if MaxBuffer > 0 && len(vs)+n > MaxBuffer {
+				count++
+				continue
+			}
+			vs = f.Fill()
+		}
+		for {
+			rs, err := debugHRDLHeaders(vs, f.Partial || len(f.Holes) > 0)
+			count++
+			if err != nil {
+				invalid++
+				errs.Println(err)
+			} else if bs, keep := Hooks.Frame(vs[:len(vs)-len(rs)]); keep && fw != nil {
+				if err := writeHadock(fw, bs); err != nil {
+					errs.Println(err)
+				}
+			}
+			if len(rs) == 0 || err != nil {
+				break
+			}
+			vs = rs
+		}
+	}
+	errs.Flush()
+	if err := st.Close(); err != nil {
+		logger.Println(err)
+	}
+	closeHooks()
+	if err := Recorder.Close(); err != nil {
+		logger.Println(err)
+	}
+	logger.Printf("%d frames reassembled (%d invalid, %d partial, %d with missing cadus, %dKB dropped over budget)", count, invalid, partial, holed, dropped>>10)
+	alerts.Close()
+	if <-failures > 0 {
+		os.Exit(ExitSource)
+	}
+}
+
+type hole struct {
+	Offset int
+	Len    int
+}
+
+type frame struct {
+	Data    []byte
+	Partial bool
+	Holes   []hole
+}
+
+func (f frame) Missing() int {
+	var n int
+	for _, h := range f.Holes {
+		n += h.Len
+	}
+	return n
+}
+
+// Fill returns the data of the frame with the bytes missing in its holes set
+// to zero.
+func (f frame) Fill() []byte {
+	if len(f.Holes) == 0 {
+		return f.Data
+	}
+	var (
+		vs   = make([]byte, 0, len(f.Data)+f.Missing())
+		prev int
+	)
+	for _, h := range f.Holes {
+		vs = append(vs, f.Data[prev:h.Offset]...)
+		vs = append(vs, make([]byte, h.Len)...)
+		prev = h.Offset
+	}
+	return append(vs, f.Data[prev:]...)
+}
+
+// splitHoles removes the holes located in the first n bytes of the buffer and
+// rebases the remaining ones on the bytes kept after them. A hole at offset n
+// is made of the cadus lost before the one holding the next sync word, so it
+// ends the first frame.
+func splitHoles(holes *[]hole, n int) []hole {
+	var head, tail []hole
+	for _, h := range *holes {
+		if h.Offset <= n {
+			head = append(head, h)
+		} else {
+			h.Offset -= n
+			tail = append(tail, h)
+		}
+	}
+	*holes = tail
+	return head
+}
+
+func reassembleCadus(ctx context.Context, queue <-chan *TimeCadu, limit int, dropped *int) <-chan frame {
+	q := make(chan frame)
+	go func() {
+		defer close(q)
+		var (
+			prev  *TimeCadu
+			holes []hole
+		)
+
+		bs := make([]byte, 0, 8<<20)
+		for c := range queue {
+			if delta := int(c.Missing(prev)); delta > 0 {
+				holes = append(holes, hole{Offset: len(bs), Len: delta * Profile.BodyLen()})
+			}
+			prev = c
+
+			bs = append(bs, c.Payload...)
+			offset := len(bs) - len(c.Payload) - len(cadus.HRDLWord)
+			if offset < 0 {
+				continue
+			}
+			if ix := bytes.Index(bs[offset:], cadus.HRDLWord); len(bs) > 0 && ix >= 0 {
+				if bytes.HasPrefix(bs, cadus.HRDLWord) {
+					vs := make([]byte, offset+ix)
+					copy(vs, bs[:offset+ix])
+					select {
+					case q <- frame{Data: vs, Holes: splitHoles(&holes, offset+ix)}:
+					case <-ctx.Done():
+						return
+					}
+				} else {
+					splitHoles(&holes, offset+ix)
+				}
+				bs = bs[offset+ix:]
+			}
+			if limit > 0 && len(bs) > limit {
+				*dropped += len(bs)
+				bs, holes = bs[:0], nil
+			}
+		}
+		if bytes.HasPrefix(bs, cadus.HRDLWord) {
+			select {
+			case q <- frame{Data: bs, Partial: true, Holes: holes}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return q
+}
+
+func writeHadock(w io.Writer, vs []byte) error {
+	if len(vs) < 8 {
+		return fmt.Errorf("frame too short (%d bytes)", len(vs))
+	}
+	vs = vs[8:]
+
+	var buf bytes.Buffer
+	buf.Write(cadus.HRDLWord)
+	binary.Write(&buf, binary.BigEndian, uint16(Hadock&0xF)<<12|uint16(Version&0xF)<<8|uint16(Mode&0xFF))
+	binary.Write(&buf, binary.BigEndian, uint32(len(vs)))
+	buf.Write(vs)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func debugHRDLHeaders(bs []byte, partial bool) ([]byte, error) {
+	var (
+		sync    uint32
+		length  uint32
+		channel uint8
+		origin  uint8
+		counter uint32
+		coarse  uint32
+		fine    uint16
+		spare   uint16
+		digest  uint32
+	)
+	r := bytes.NewReader(bs)
+	binary.Read(r, binary.BigEndian, &sync)
+	if sync != binary.BigEndian.Uint32(cadus.HRDLWord) {
+		return nil, fmt.Errorf("invalid sync word: found %08x", sync)
+	}
+	binary.Read(r, binary.LittleEndian, &length)
+
+	sum := cadus.SumVMU()
+	rs := io.TeeReader(r, sum)
+	binary.Read(rs, binary.LittleEndian, &channel)
+	binary.Read(rs, binary.LittleEndian, &origin)
+	binary.Read(rs, binary.LittleEndian, &spare)
+	binary.Read(rs, binary.LittleEndian, &counter)
+	binary.Read(rs, binary.LittleEndian, &coarse)
+	binary.Read(rs, binary.LittleEndian, &fine)
+	binary.Read(rs, binary.LittleEndian, &spare)
+
+	when := onboardTime(coarse, fine)
+	if n, err := io.CopyN(ioutil.Discard, rs, int64(length-16)); err != nil {
+		return nil, LengthError{Want: int(length), Got: int(n)}
+	}
+	binary.Read(r, binary.LittleEndian, &digest)
+
+	log.Printf(VMURowPattern, len(bs), sync, length, channel, origin, counter, formatTime(when), sum.Sum32() == digest, partial)
+
+	var vs []byte
+	if n := r.Len(); n > 0 {
+		vs = make([]byte, n)
+		io.ReadFull(r, vs)
+	}
+	return vs, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/busoc/cadus"
+)
+
+func TestSplitHoles(t *testing.T) {
+	data := []struct {
+		Name  string
+		Holes []hole
+		Len   int
+		Head  []hole
+		Tail  []hole
+	}{
+		{Name: "none", Len: 100},
+		{Name: "head", Holes: []hole{{Offset: 10, Len: 5}}, Len: 100, Head: []hole{{Offset: 10, Len: 5}}},
+		{Name: "tail", Holes: []hole{{Offset: 110, Len: 5}}, Len: 100, Tail: []hole{{Offset: 10, Len: 5}}},
+		{Name: "boundary", Holes: []hole{{Offset: 100, Len: 5}}, Len: 100, Head: []hole{{Offset: 100, Len: 5}}},
+		{
+			Name:  "both",
+			Holes: []hole{{Offset: 0, Len: 1}, {Offset: 50, Len: 2}, {Offset: 150, Len: 3}},
+			Len:   100,
+			Head:  []hole{{Offset: 0, Len: 1}, {Offset: 50, Len: 2}},
+			Tail:  []hole{{Offset: 50, Len: 3}},
+		},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			holes := append([]hole(nil), d.Holes...)
+			head := splitHoles(&holes, d.Len)
+			if !reflect.DeepEqual(head, d.Head) {
+				t.Errorf("head mismatched: want %v, got %v", d.Head, head)
+			}
+			if !reflect.DeepEqual(holes, d.Tail) {
+				t.Errorf("tail mismatched: want %v, got %v", d.Tail, holes)
+			}
+		})
+	}
+}
+
+// hrdlStream returns a frame of n bytes starting with the hrdl sync word.
+func hrdlStream(n int, fill byte) []byte {
+	return append(append([]byte(nil), cadus.HRDLWord...), bytes.Repeat([]byte{fill}, n-len(cadus.HRDLWord))...)
+}
+
+func TestReassembleCadus(t *testing.T) {
+	z := Profile.BodyLen()
+	data := []struct {
+		Name    string
+		Stream  [][]byte
+		Skip    []int
+		Limit   int
+		Frames  []frame
+		Dropped int
+	}{
+		{
+			Name:   "frames",
+			Stream: [][]byte{hrdlStream(z+100, 1), hrdlStream(2*z, 2), hrdlStream(10, 3)},
+			Frames: []frame{
+				{Data: hrdlStream(z+100, 1)},
+				{Data: hrdlStream(2*z, 2)},
+				{Data: hrdlStream(10, 3), Partial: true},
+			},
+		},
+		{
+			Name:   "hole",
+			Stream: [][]byte{hrdlStream(3*z, 1), hrdlStream(z, 2)},
+			Skip:   []int{1},
+			Frames: []frame{
+				{Data: append(hrdlStream(z, 1), bytes.Repeat([]byte{1}, z)...), Holes: []hole{{Offset: z, Len: z}}},
+				{Data: hrdlStream(z, 2), Partial: true},
+			},
+		},
+		{
+			Name:   "max-buffer",
+			Stream: [][]byte{hrdlStream(3*z, 1), hrdlStream(2*z+100, 2), hrdlStream(z-100, 3)},
+			Limit:  5 * z / 2,
+			Frames: []frame{
+				{Data: hrdlStream(2*z+100, 2)},
+				{Data: hrdlStream(z-100, 3), Partial: true},
+			},
+			Dropped: 3 * z,
+		},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			var (
+				stream = bytes.Join(d.Stream, nil)
+				queue  = make(chan *TimeCadu, len(stream)/z+1)
+			)
+			for i := 0; len(stream) > 0; i++ {
+				n := z
+				if len(stream) < n {
+					n = len(stream)
+				}
+				skip := false
+				for _, s := range d.Skip {
+					skip = skip || s == i
+				}
+				if !skip {
+					c := cadus.Cadu{Header: &cadus.Header{Sequence: uint32(i)}, Payload: stream[:n]}
+					queue <- &TimeCadu{Cadu: &c}
+				}
+				stream = stream[n:]
+			}
+			close(queue)
+
+			var (
+				dropped int
+				frames  []frame
+			)
+			for f := range reassembleCadus(context.Background(), queue, d.Limit, &dropped) {
+				frames = append(frames, f)
+			}
+			if len(frames) != len(d.Frames) {
+				t.Fatalf("want %d frames, got %d", len(d.Frames), len(frames))
+			}
+			for i, f := range frames {
+				w := d.Frames[i]
+				if !bytes.Equal(f.Data, w.Data) || f.Partial != w.Partial {
+					t.Errorf("frame %d: want %d bytes (partial: %t), got %d bytes (partial: %t)", i, len(w.Data), w.Partial, len(f.Data), f.Partial)
+				}
+				if !reflect.DeepEqual(f.Holes, w.Holes) {
+					t.Errorf("frame %d: want holes %v, got %v", i, w.Holes, f.Holes)
+				}
+			}
+			if dropped != d.Dropped {
+				t.Errorf("want %d bytes dropped, got %d", d.Dropped, dropped)
+			}
+		})
+	}
+}
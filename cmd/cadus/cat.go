@@ -0,0 +1,688 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+type hookFunc func(int, []byte)
+
+type byFunc func([]byte) (uint16, int)
+
+type Coze struct {
+	Count   int
+	Size    int
+	Bad     int
+	Bigger  int
+	Smaller int
+}
+
+type Counter struct {
+	Count   uint64
+	Size    uint64
+	Missing uint64
+	First   uint32
+	Last    uint32
+	When    time.Time
+	Gaps    []Gap
+	Jumps   []Gap
+	Skew    Skew
+	Fit     Fit
+}
+
+type Skew struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Sum   time.Duration
+	First time.Duration
+	Last  time.Duration
+}
+
+func (s *Skew) Update(d time.Duration) {
+	if s.Count == 0 {
+		s.Min, s.Max, s.First = d, d, d
+	}
+	if d < s.Min {
+		s.Min = d
+	}
+	if d > s.Max {
+		s.Max = d
+	}
+	s.Count++
+	s.Sum += d
+	s.Last = d
+}
+
+func (s Skew) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+type Fit struct {
+	Count int
+	Base  time.Time
+	sx    float64
+	sy    float64
+	sxx   float64
+	sxy   float64
+}
+
+func (f *Fit) Update(when time.Time, d time.Duration) {
+	if f.Count == 0 {
+		f.Base = when
+	}
+	x, y := when.Sub(f.Base).Seconds(), d.Seconds()
+	f.Count++
+	f.sx += x
+	f.sy += y
+	f.sxx += x * x
+	f.sxy += x * y
+}
+
+func (f Fit) Estimate() (float64, time.Duration) {
+	n := float64(f.Count)
+	if div := n*f.sxx - f.sx*f.sx; f.Count > 1 && div != 0 {
+		slope := (n*f.sxy - f.sx*f.sy) / div
+		offset := (f.sy - slope*f.sx) / n
+		return slope * 1e6, time.Duration(offset * float64(time.Second))
+	}
+	if f.Count > 0 {
+		return 0, time.Duration(f.sy / n * float64(time.Second))
+	}
+	return 0, 0
+}
+
+type Gap struct {
+	Last   uint32
+	Next   uint32
+	Before time.Time
+	After  time.Time
+}
+
+func (g Gap) Missing() uint64 {
+	return sequenceDelta(g.Next, g.Last)
+}
+
+func (g Gap) Backward() uint64 {
+	return sequenceJump(g.Next, g.Last)
+}
+
+func (g Gap) Duration() time.Duration {
+	return g.After.Sub(g.Before)
+}
+
+const (
+	rawPattern    = "%6d | %x | %x | %x | %x | %12d | %12d"
+	fieldsPattern = "%6d | %7d | %02x | %s | %9d | %6d | %s | %s | %02x | %02x | %7d | %2d | %2d | %s"
+)
+
+var (
+	ChannelAt    = 8
+	ChannelSeqAt = 12
+	OriginAt     = 9
+	SourceAt     = 47
+	OriginSeqAt  = 27
+)
+
+func runCat(args []string) {
+	set := flag.NewFlagSet("cat", flag.ExitOnError)
+	kind := set.String("by", "channel", "report by channel or origin")
+	debug := set.String("debug", "", "dump packet headers")
+	hrdfe := set.Bool("hrdfe", false, "hrdfe packet")
+	timeline := set.String("timeline", "", "report frames per minute as table or csv")
+	gaps := set.Bool("gaps", false, "report every gap found in sequence check")
+	skew := set.Bool("skew", false, "report skew between reception and VMU times (hrdfe only)")
+	fit := set.Bool("fit", false, "estimate drift and offset of VMU times against reception times (hrdfe only)")
+	clockFlags(set)
+	profileFlags(set)
+	hookFlags(set)
+	set.IntVar(&ChannelAt, "channel-at", ChannelAt, "offset of the channel in VMU packets")
+	set.IntVar(&ChannelSeqAt, "channel-seq-at", ChannelSeqAt, "offset of the channel sequence counter in VMU packets")
+	set.IntVar(&OriginAt, "origin-at", OriginAt, "offset of the origin in VMU packets")
+	set.IntVar(&SourceAt, "source-at", SourceAt, "offset of the source in VMU packets")
+	set.IntVar(&OriginSeqAt, "origin-seq-at", OriginSeqAt, "offset of the origin sequence counter in VMU packets")
+	set.IntVar(&MaxBuffer, "max-buffer", MaxBuffer, "maximum bytes buffered while reassembling a frame before dropping them (0: no limit)")
+	set.IntVar(&MaxKeys, "max-keys", MaxKeys, "maximum number of channels or origins tracked before counting the others in an overflow bucket (0: no limit)")
+	set.IntVar(&Workers, "workers", Workers, "number of workers verifying frame checksums")
+	set.Int64Var(&StartOffset, "from-offset", StartOffset, "byte offset in the first file where reassembly starts (rounded down to a cadu boundary)")
+	args = parseArgs(set, args)
+	if err := Profile.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := loadHooks(context.Background()); err != nil {
+		log.Fatalln(err)
+	}
+
+	var hook hookFunc
+	switch *debug {
+	case "raw":
+		hook = debugRaw
+	case "header":
+		hook = debugHeaders(false)
+	default:
+	}
+	var by byFunc
+	switch *kind {
+	case "channel":
+		by = func(vs []byte) (uint16, int) {
+			return uint16(vs[ChannelAt]), ChannelSeqAt
+		}
+	case "origin":
+		by = func(vs []byte) (uint16, int) {
+			return uint16(vs[OriginAt])<<8 | uint16(vs[SourceAt]), OriginSeqAt
+		}
+	default:
+		log.Fatalf("%s unsupported", *kind)
+	}
+
+	var tl *Timeline
+	switch *timeline {
+	case "":
+	case "table", "csv":
+		tl = NewTimeline(by)
+		hook = chainHooks(hook, tl.Update)
+	default:
+		log.Fatalf("%s unsupported timeline format", *timeline)
+	}
+
+	var rs []io.Reader
+	for _, a := range args {
+		r, err := openFile(a)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		defer r.Close()
+		if len(rs) == 0 && StartOffset > 0 {
+			size := int64(Profile.Size())
+			if *hrdfe {
+				size += 8
+			}
+			if _, err := r.Seek(StartOffset-StartOffset%size, io.SeekStart); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		rs = append(rs, r)
+	}
+	status, reports, err := reassembleHRDL(io.MultiReader(rs...), *hrdfe, by, hook)
+	closeHooks()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printReports(*kind, status, reports)
+	if *gaps {
+		log.Println()
+		printHRDLGaps(*kind, reports)
+	}
+	if *skew && *hrdfe {
+		log.Println()
+		printSkews(*kind, reports)
+	}
+	if *fit && *hrdfe {
+		log.Println()
+		printFits(*kind, reports)
+	}
+	if tl != nil {
+		log.Println()
+		tl.Print(*kind, *timeline)
+	}
+}
+
+func chainHooks(hs ...hookFunc) hookFunc {
+	var fs []hookFunc
+	for _, h := range hs {
+		if h != nil {
+			fs = append(fs, h)
+		}
+	}
+	if len(fs) == 0 {
+		return nil
+	}
+	return func(i int, vs []byte) {
+		for _, f := range fs {
+			f(i, vs)
+		}
+	}
+}
+
+type Bucket struct {
+	Count int
+	Size  int
+	Bad   int
+}
+
+type Timeline struct {
+	by      byFunc
+	buckets map[uint16]map[time.Time]*Bucket
+}
+
+func NewTimeline(by byFunc) *Timeline {
+	return &Timeline{
+		by:      by,
+		buckets: make(map[uint16]map[time.Time]*Bucket),
+	}
+}
+
+func (t *Timeline) Update(_ int, vs []byte) {
+	k, _ := t.by(vs)
+	bs, ok := t.buckets[k]
+	if !ok {
+		bs = make(map[time.Time]*Bucket)
+		t.buckets[k] = bs
+	}
+	w := vmuTime(vs).Truncate(time.Minute)
+
+	b, ok := bs[w]
+	if !ok {
+		b = &Bucket{}
+		bs[w] = b
+	}
+	b.Count++
+	b.Size += len(vs)
+	if !validSum(vs) {
+		b.Bad++
+	}
+}
+
+func (t *Timeline) Print(kind, format string) {
+	const (
+		rowPattern = "%s(%s) %02x | %s | %8d | %6d | %9dKB"
+		csvPattern = "%s,%s,%02x,%s,%d,%d,%d"
+	)
+	keys := make([]int, 0, len(t.buckets))
+	for k := range t.buckets {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+
+	if format == "csv" {
+		log.Printf("%s,mode,key,minute,count,bad,bytes", kind)
+	} else {
+		log.Printf("timeline by %s(s):", kind)
+	}
+	for _, k := range keys {
+		bs := t.buckets[uint16(k)]
+		ws := make([]time.Time, 0, len(bs))
+		for w := range bs {
+			ws = append(ws, w)
+		}
+		sort.Slice(ws, func(i, j int) bool { return ws[i].Before(ws[j]) })
+
+		mode := modeOf(uint16(k))
+		for _, w := range ws {
+			b := bs[w]
+			when := w.Format("2006-01-02 15:04")
+			if format == "csv" {
+				log.Printf(csvPattern, kind, mode, k&0xFF, when, b.Count, b.Bad, b.Size)
+			} else {
+				log.Printf(rowPattern, kind, mode, k&0xFF, when, b.Count, b.Bad, b.Size>>10)
+			}
+		}
+	}
+}
+
+func modeOf(b uint16) string {
+	if m := b >> 8; m >= 0x61 && m <= 0x66 {
+		return "pb"
+	}
+	return "rt"
+}
+
+func printReports(kind string, status map[uint16]*Coze, reports map[uint16]*Counter) {
+	log.Printf("status by %s(s):", kind)
+	var z Coze
+	for b, c := range status {
+		z.Count += c.Count
+		z.Bad += c.Bad
+		z.Size += c.Size
+
+		mode := modeOf(b)
+		log.Printf("%s(%s) %02x = %8d: %6d bad, %8d length error (big: %6d, small: %6d), %9dKB", kind, mode, b&0xFF, c.Count, c.Bad, c.Bigger+c.Smaller, c.Bigger, c.Smaller, c.Size>>10)
+	}
+
+	log.Println()
+	log.Printf("sequence check by %s(s):", kind)
+	for b, c := range reports {
+		mode := modeOf(b)
+		log.Printf("%s(%s) %02x: first: %10d - last: %10d - missing: %10d - backward jumps: %6d", kind, mode, b&0xFF, c.First, c.Last, c.Missing, len(c.Jumps))
+	}
+	log.Println()
+	log.Printf("%d VMU packets (%d bad, %dKB)", z.Count, z.Bad, z.Size>>10)
+}
+
+func printHRDLGaps(kind string, reports map[uint16]*Counter) {
+	const (
+		row  = "%s(%s) %02x: last: %10d (%s) - next: %10d (%s) - missing: %10d - duration: %s"
+		jump = "%s(%s) %02x: last: %10d (%s) - next: %10d (%s) - backward jump: %10d"
+	)
+
+	log.Printf("gaps by %s(s):", kind)
+	for b, c := range reports {
+		mode := modeOf(b)
+		for _, g := range c.Gaps {
+			before, after := formatTime(g.Before), formatTime(g.After)
+			log.Printf(row, kind, mode, b&0xFF, g.Last, before, g.Next, after, g.Missing(), g.Duration())
+		}
+		for _, g := range c.Jumps {
+			before, after := formatTime(g.Before), formatTime(g.After)
+			log.Printf(jump, kind, mode, b&0xFF, g.Last, before, g.Next, after, g.Backward())
+		}
+	}
+}
+
+func printSkews(kind string, reports map[uint16]*Counter) {
+	const row = "%s(%s) %02x: %8d packets - min: %12s - max: %12s - mean: %12s - drift: %12s"
+
+	log.Printf("skew by %s(s):", kind)
+	for b, c := range reports {
+		s := c.Skew
+		log.Printf(row, kind, modeOf(b), b&0xFF, s.Count, s.Min, s.Max, s.Mean(), s.Last-s.First)
+	}
+}
+
+func printFits(kind string, reports map[uint16]*Counter) {
+	const row = "%s(%s) %02x: %8d packets - start: %s - offset: %12s - drift: %10.3fppm"
+
+	log.Printf("drift by %s(s):", kind)
+	for b, c := range reports {
+		ppm, offset := c.Fit.Estimate()
+		log.Printf(row, kind, modeOf(b), b&0xFF, c.Fit.Count, formatTime(c.Fit.Base), offset, ppm)
+	}
+}
+
+func debugRaw(i int, vs []byte) {
+	z := binary.LittleEndian.Uint32(vs[4:])
+	sum := vs[len(vs)-4:]
+	log.Printf(rawPattern, i, vs[:8], vs[8:24], vs[24:48], sum, z, len(vs)-12)
+}
+
+func debugHeaders(hrd bool) hookFunc {
+	deltas := make(map[uint8]uint32)
+	return func(i int, vs []byte) {
+		// HRDL Frame Header
+		var (
+			sync     uint32
+			size     uint32
+			channel  uint8
+			source   uint8
+			sequence uint32
+			coarse   uint32
+			fine     uint16
+			spare    uint16
+			property uint8
+			stream   uint16
+			counter  uint32
+			acqtime  time.Duration
+			auxtime  time.Duration
+			origin   uint8
+		)
+
+		r := bytes.NewReader(vs)
+		binary.Read(r, binary.BigEndian, &sync)
+		binary.Read(r, binary.LittleEndian, &size)
+		binary.Read(r, binary.LittleEndian, &channel)
+		binary.Read(r, binary.LittleEndian, &source)
+		binary.Read(r, binary.LittleEndian, &spare)
+		binary.Read(r, binary.LittleEndian, &sequence)
+		binary.Read(r, binary.LittleEndian, &coarse)
+		binary.Read(r, binary.LittleEndian, &fine)
+		binary.Read(r, binary.LittleEndian, &spare)
+		binary.Read(r, binary.LittleEndian, &property)
+		binary.Read(r, binary.LittleEndian, &stream)
+		binary.Read(r, binary.LittleEndian, &counter)
+		binary.Read(r, binary.LittleEndian, &acqtime)
+		binary.Read(r, binary.LittleEndian, &auxtime)
+		binary.Read(r, binary.LittleEndian, &origin)
+
+		at := formatTime(cadus.GPS.Add(acqtime - leap()))
+		xt := cadus.GPS.Add(auxtime - leap()).Format("15:04:05.000")
+		vt := formatTime(onboardTime(coarse, fine))
+
+		tp, st := property>>4, property&0xF
+		var upi string
+		switch bs := make([]byte, 32); tp {
+		case 1:
+			io.ReadFull(r, bs)
+			upi = string(bytes.Trim(bs, "\x00"))
+		case 2:
+			io.ReadFull(r, make([]byte, 20))
+			io.ReadFull(r, bs)
+			upi = string(bytes.Trim(bs, "\x00"))
+		default:
+			upi = "UNKNOWN"
+		}
+		k, s := channel, sequence
+		if hrd {
+			k, s = origin, counter
+		}
+		var delta uint64
+		if last, ok := deltas[k]; ok && last+1 != s {
+			delta = sequenceDelta(s, last)
+		}
+		deltas[k] = s
+
+		log.Printf(fieldsPattern, i, size, channel, vt, sequence, delta, at, xt, source, origin, counter, tp, st, upi)
+	}
+}
+
+func vmuTime(vs []byte) time.Time {
+	coarse, fine := binary.LittleEndian.Uint32(vs[16:]), binary.LittleEndian.Uint16(vs[20:])
+	return onboardTime(coarse, fine)
+}
+
+var Workers = runtime.NumCPU()
+
+const overflowKey = 0xFFFF
+
+var MaxKeys = 256
+
+type summed struct {
+	index int
+	data  []byte
+	size  int
+	when  time.Time
+	valid chan bool
+}
+
+func reassembleHRDL(r io.Reader, hrdfe bool, by byFunc, hook hookFunc) (map[uint16]*Coze, map[uint16]*Counter, error) {
+	rs := cadus.NewHRDLReader(r, hrdfe)
+	rs.Profile = Profile
+	rs.Limit = MaxBuffer
+	rs.Tolerant = Profile.Tolerant
+
+	var (
+		frames = make(chan *summed, Workers*4)
+		jobs   = make(chan *summed, Workers*4)
+		rerr   error
+		short  = minFrameLen()
+		skip   int
+	)
+	for i := 0; i < Workers || i == 0; i++ {
+		go func() {
+			for f := range jobs {
+				f.valid <- validSum(f.data)
+			}
+		}()
+	}
+	go func() {
+		defer func() {
+			close(jobs)
+			close(frames)
+		}()
+		xs := make([]byte, 8<<20)
+		for i := 1; ; i++ {
+			n, err := rs.Read(xs)
+			if _, ok := err.(cadus.CorruptedError); ok && rs.Tolerant {
+				skip++
+				continue
+			}
+			if err != nil && err != io.EOF {
+				rerr = err
+				return
+			}
+			if n == 0 || err == io.EOF {
+				return
+			}
+			vs := xs[:n]
+			if n < short {
+				if rs.Tolerant {
+					skip++
+					continue
+				}
+				rerr = fmt.Errorf("frame %d: %d bytes too short (at least %d bytes)", i, n, short)
+				return
+			}
+			if !bytes.Equal(vs[:len(cadus.HRDLWord)], cadus.HRDLWord) {
+				if rs.Tolerant {
+					skip++
+					continue
+				}
+				rerr = cadus.ErrSyncword
+				return
+			}
+			if i := bytes.Index(vs, cadus.HRDLWord); i >= len(cadus.HRDLWord) {
+				if rs.Tolerant {
+					skip++
+					continue
+				}
+				rerr = cadus.ErrMultiple
+				return
+			}
+			vs, keep := Hooks.Frame(vs)
+			if !keep {
+				continue
+			}
+			f := summed{
+				index: i,
+				data:  append([]byte(nil), vs...),
+				size:  n,
+				when:  rs.Reception(),
+				valid: make(chan bool, 1),
+			}
+			jobs <- &f
+			frames <- &f
+		}
+	}()
+
+	var (
+		status   = make(map[uint16]*Coze)
+		reports  = make(map[uint16]*Counter)
+		overflow bool
+	)
+	for f := range frames {
+		vs, n := f.data, f.size
+		if hook != nil {
+			hook(f.index, vs)
+		}
+
+		k, six := by(vs)
+		c, ok := status[k]
+		if !ok && MaxKeys > 0 && len(status) >= MaxKeys {
+			if !overflow {
+				log.Printf("more than %d keys found, counting frames of further keys under %04x without sequence checks", MaxKeys, overflowKey)
+				overflow = true
+			}
+			k = overflowKey
+			c, ok = status[k]
+		}
+		if !ok {
+			c = &Coze{}
+		}
+		c.Count++
+		c.Size += n
+
+		if !<-f.valid {
+			c.Bad++
+		}
+		switch z, n := binary.LittleEndian.Uint32(vs[4:]), len(vs)-12; {
+		default:
+		case int(z) > n:
+			c.Smaller++
+		case int(z) < n:
+			c.Bigger++
+		}
+		status[k] = c
+		if k == overflowKey && overflow {
+			continue
+		}
+
+		v, ok := reports[k]
+		seq, when := binary.LittleEndian.Uint32(vs[six:]), vmuTime(vs)
+		if !ok {
+			v = &Counter{First: seq, Last: seq}
+		} else {
+			if delta := sequenceDelta(seq, v.Last); delta > 0 {
+				v.Missing += delta
+				v.Gaps = append(v.Gaps, Gap{Last: v.Last, Next: seq, Before: v.When, After: when})
+			}
+			if sequenceJump(seq, v.Last) > 0 {
+				v.Jumps = append(v.Jumps, Gap{Last: v.Last, Next: seq, Before: v.When, After: when})
+			}
+			v.Last = seq
+		}
+		if hrdfe {
+			d := f.when.Sub(when)
+			v.Skew.Update(d)
+			v.Fit.Update(when, d)
+		}
+		v.When = when
+		v.Count++
+		reports[k] = v
+	}
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	if rs.Dropped > 0 {
+		log.Printf("%dKB dropped over the %dKB reassembly budget", rs.Dropped>>10, MaxBuffer>>10)
+	}
+	if skip > 0 {
+		log.Printf("%d malformed frames skipped", skip)
+	}
+	return status, reports, nil
+}
+
+func minFrameLen() int {
+	z := 22
+	for _, n := range []int{ChannelAt + 1, ChannelSeqAt + 4, OriginAt + 1, SourceAt + 1, OriginSeqAt + 4} {
+		if n > z {
+			z = n
+		}
+	}
+	return z
+}
+
+func validSum(vs []byte) bool {
+	if len(vs) < 12 {
+		return false
+	}
+	sum := cadus.SumVMU()
+	sum.Write(vs[8 : len(vs)-4])
+	return sum.Sum32() == binary.LittleEndian.Uint32(vs[len(vs)-4:])
+}
+
+func sequenceDelta(current, last uint32) uint64 {
+	if current > last {
+		return uint64(current) - uint64(last) - 1
+	}
+	return 0
+}
+
+func sequenceJump(current, last uint32) uint64 {
+	if current < last {
+		return uint64(last) - uint64(current)
+	}
+	return 0
+}
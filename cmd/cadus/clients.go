@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	MaxClients   int
+	ClientsEvery time.Duration
+)
+
+func clientFlags(set *flag.FlagSet) {
+	set.IntVar(&MaxClients, "max-clients", MaxClients, "maximum number of concurrent tcp clients, the others being disconnected at once (0: no limit)")
+	set.DurationVar(&ClientsEvery, "clients-every", ClientsEvery, "interval between reports of the connected tcp clients (0: only on disconnect)")
+}
+
+type client struct {
+	Addr    string
+	Since   time.Time
+	Frames  int
+	Dropped int
+}
+
+func (c *client) Add(frames, dropped int) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	c.Frames += frames
+	c.Dropped += dropped
+}
+
+type clients struct {
+	mu       sync.Mutex
+	active   map[*client]struct{}
+	rejected int
+	once     sync.Once
+}
+
+var registry = clients{active: make(map[*client]struct{})}
+
+// Accept registers c on a tcp server with the given name and returns nil,
+// after closing c, when MaxClients clients are already connected.
+func (cs *clients) Accept(server string, c net.Conn) *client {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.once.Do(func() {
+		if ClientsEvery > 0 {
+			go cs.report(ClientsEvery)
+		}
+	})
+	addr := c.RemoteAddr().String()
+	if MaxClients > 0 && len(cs.active) >= MaxClients {
+		cs.rejected++
+		log.Printf("[tcp] %s: %s rejected (%d clients connected, %d rejected)", server, addr, len(cs.active), cs.rejected)
+		c.Close()
+		return nil
+	}
+	x := client{Addr: addr, Since: time.Now()}
+	cs.active[&x] = struct{}{}
+	log.Printf("[tcp] %s: %s connected (%d clients)", server, addr, len(cs.active))
+	return &x
+}
+
+func (cs *clients) Done(server string, x *client) {
+	if x == nil {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.active[x]; !ok {
+		return
+	}
+	delete(cs.active, x)
+	log.Printf("[tcp] %s: %s disconnected after %s (%d cadus, %d dropped)", server, x.Addr, time.Since(x.Since).Round(time.Millisecond), x.Frames, x.Dropped)
+}
+
+func (cs *clients) report(every time.Duration) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for range tick.C {
+		cs.mu.Lock()
+		xs := make([]*client, 0, len(cs.active))
+		for x := range cs.active {
+			xs = append(xs, x)
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i].Since.Before(xs[j].Since) })
+		for _, x := range xs {
+			log.Printf("[tcp] %s: connected for %s (%d cadus, %d dropped)", x.Addr, time.Since(x.Since).Round(time.Second), x.Frames, x.Dropped)
+		}
+		cs.mu.Unlock()
+	}
+}
+
+// clientConn counts the writes to a client of make, each of them carrying one
+// frame, and unregisters it once closed.
+type clientConn struct {
+	net.Conn
+	server string
+	client *client
+	once   sync.Once
+}
+
+func trackClient(server string, c net.Conn) net.Conn {
+	x := registry.Accept(server, c)
+	if x == nil {
+		return nil
+	}
+	return &clientConn{Conn: c, server: server, client: x}
+}
+
+func (c *clientConn) Write(bs []byte) (int, error) {
+	n, err := c.Conn.Write(bs)
+	if err != nil {
+		c.client.Add(0, 1)
+	} else {
+		c.client.Add(1, 0)
+	}
+	return n, err
+}
+
+func (c *clientConn) Close() error {
+	c.once.Do(func() {
+		registry.Done(c.server, c.client)
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+var (
+	Leap   = 0
+	Epoch  = epoch(cadus.GPS)
+	Offset time.Duration
+	Drift  float64
+)
+
+type epoch time.Time
+
+func (e *epoch) String() string {
+	return time.Time(*e).Format(time.RFC3339)
+}
+
+func (e *epoch) Set(str string) error {
+	for _, f := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(f, str); err == nil {
+			*e = epoch(t.UTC())
+			return nil
+		}
+	}
+	_, err := time.Parse(time.RFC3339, str)
+	return err
+}
+
+func clockFlags(set *flag.FlagSet) {
+	set.IntVar(&Leap, "leap", Leap, "leap seconds between GPS and UTC")
+	set.Var(&Epoch, "epoch", "on-board epoch of coarse/fine times")
+	set.DurationVar(&Offset, "offset", Offset, "time correlation offset added to on-board times")
+	set.Float64Var(&Drift, "drift", Drift, "time correlation drift (seconds per second since epoch)")
+}
+
+func leap() time.Duration {
+	return time.Duration(Leap) * time.Second
+}
+
+func onboardTime(coarse uint32, fine uint16) time.Time {
+	e := time.Time(Epoch)
+	t := e.Add(cadus.ReadTime6(coarse, fine).Sub(cadus.UNIX))
+	t = t.Add(Offset + time.Duration(Drift*float64(t.Sub(e))))
+	return t.Add(-leap())
+}
+
+var (
+	Layout   = TimeFormat
+	Location = time.UTC
+)
+
+var layouts = map[string]string{
+	"default": TimeFormat,
+	"iso":     "2006-01-02T15:04:05.000Z07:00",
+	"doy":     "2006-002T15:04:05.000",
+	"unix":    "",
+}
+
+func setTimeFormat(format, tz string) error {
+	if l, ok := layouts[format]; ok {
+		Layout = l
+	} else {
+		Layout = format
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return err
+	}
+	Location = loc
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if Layout == "" {
+		return fmt.Sprintf("%d.%03d", t.Unix(), t.Nanosecond()/int(time.Millisecond))
+	}
+	return t.In(Location).Format(Layout)
+}
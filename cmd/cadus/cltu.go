@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/busoc/cadus"
+)
+
+func runCLTU(args []string) {
+	set := flag.NewFlagSet("cltu", flag.ExitOnError)
+	quiet := set.Bool("q", false, "suppress per-cltu output and exit non-zero when invalid cltus are found")
+	file := set.String("o", "", "write decoded data to file")
+	correct := set.Bool("correct", false, "correct single bit errors in codeblocks")
+	raw := set.Bool("raw", false, "input is a sequence of codeblocks without start and tail sequences")
+	dry := set.Bool("n", false, "report what would be written to the output file without writing it")
+	args = parseArgs(set, args)
+
+	if *quiet {
+		rows.SetOutput(ioutil.Discard)
+	}
+	var w io.Writer = ioutil.Discard
+	if *file != "" && *dry {
+		f := dryRun(*file)
+		defer f.Close()
+		w = f
+	} else if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	var rs []io.Reader
+	for _, a := range args {
+		r, err := os.Open(a)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer r.Close()
+		rs = append(rs, r)
+	}
+	var (
+		r       = bufio.NewReader(io.MultiReader(rs...))
+		invalid int
+	)
+	if *raw {
+		invalid = verifyCodeblocks(r, w, *correct)
+	} else {
+		invalid = decodeCLTUs(r, w, *correct)
+	}
+	if invalid > 0 && *quiet {
+		os.Exit(1)
+	}
+}
+
+func verifyCodeblocks(r io.Reader, w io.Writer, correct bool) int {
+	const line = "%8d | %x | %s"
+
+	var (
+		count     int
+		corrected int
+		rejected  int
+		block     = make([]byte, cadus.CodeblockLen)
+	)
+	for {
+		if _, err := io.ReadFull(r, block); err != nil {
+			if err != io.EOF {
+				log.Println(err)
+			}
+			break
+		}
+		switch n, err := cadus.VerifyCodeblock(block, correct); {
+		case err != nil:
+			e := err.(cadus.CodeblockError)
+			e.Block = count
+			rejected++
+			rows.Printf(line, count, block, e)
+		case n > 0:
+			corrected++
+			rows.Printf(line, count, block, "corrected")
+		}
+		count++
+		if _, err := w.Write(block[:cadus.CodeblockInfo]); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	log.Printf("%d codeblocks verified (%d corrected, %d rejected)", count, corrected, rejected)
+	return rejected
+}
+
+func decodeCLTUs(r *bufio.Reader, w io.Writer, correct bool) int {
+	const line = "%8d | %6d blocks | %6d bytes | %4d corrected | %4d rejected | %s"
+
+	var (
+		count     int
+		invalid   int
+		blocks    int
+		corrected int
+		rejected  int
+		size      int
+	)
+	for {
+		c, err := cadus.DecodeCLTU(r, correct)
+		if err != nil {
+			if err != io.EOF {
+				log.Println(err)
+			}
+			break
+		}
+		count++
+		blocks += c.Blocks
+		corrected += c.Corrected
+		rejected += c.Bad
+		size += len(c.Data)
+
+		msg := "-"
+		if c.Error != nil {
+			invalid++
+			msg = c.Error.Error()
+		}
+		rows.Printf(line, count, c.Blocks, len(c.Data), c.Corrected, c.Bad, msg)
+		if _, err := w.Write(c.Data); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	log.Printf("%d cltus decoded (%d invalid, %d codeblocks, %d corrected, %d rejected, %d bytes)", count, invalid, blocks, corrected, rejected, size)
+	return invalid
+}
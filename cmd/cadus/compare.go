@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+const (
+	chainPrime = 1 << iota
+	chainBackup
+)
+
+type sighting struct {
+	Minute time.Time
+	Chains uint8
+}
+
+type chainCount struct {
+	Prime      int
+	Backup     int
+	OnlyPrime  int
+	OnlyBackup int
+}
+
+func (c *chainCount) Update(chains uint8) {
+	switch chains {
+	case chainPrime:
+		c.Prime++
+		c.OnlyPrime++
+	case chainBackup:
+		c.Backup++
+		c.OnlyBackup++
+	default:
+		c.Prime++
+		c.Backup++
+	}
+}
+
+func printCompare(queue <-chan *TimeCadu, prime string, grace time.Duration) Summary {
+	const line = "%s | %-10s | prime: %8d (%6d only) | backup: %8d (%6d only)"
+
+	var (
+		prevs   = make(map[string]map[cadus.ChannelKey]*TimeCadu)
+		pending = make(map[cadus.ChannelKey]map[uint32]*sighting)
+		total   chainCount
+		sum     Summary
+		checked time.Time
+	)
+	flush := func(cutoff time.Time) {
+		counts := make(map[time.Time]map[cadus.ChannelKey]*chainCount)
+		for k, ss := range pending {
+			for seq, s := range ss {
+				if !s.Minute.Before(cutoff) {
+					continue
+				}
+				if counts[s.Minute] == nil {
+					counts[s.Minute] = make(map[cadus.ChannelKey]*chainCount)
+				}
+				if counts[s.Minute][k] == nil {
+					counts[s.Minute][k] = &chainCount{}
+				}
+				counts[s.Minute][k].Update(s.Chains)
+				total.Update(s.Chains)
+				delete(ss, seq)
+			}
+		}
+		minutes := make([]time.Time, 0, len(counts))
+		for m := range counts {
+			minutes = append(minutes, m)
+		}
+		sort.Slice(minutes, func(i, j int) bool { return minutes[i].Before(minutes[j]) })
+		for _, m := range minutes {
+			keys := make([]cadus.ChannelKey, 0, len(counts[m]))
+			for k := range counts[m] {
+				keys = append(keys, k)
+			}
+			cadus.SortKeys(keys)
+			for _, k := range keys {
+				c := counts[m][k]
+				rows.Printf(line, formatTime(m), k, c.Prime, c.OnlyPrime, c.Backup, c.OnlyBackup)
+			}
+		}
+	}
+	for c := range queue {
+		chain := uint8(chainBackup)
+		if c.Source == prime {
+			chain = chainPrime
+		}
+		k := c.Key()
+		if prevs[c.Source] == nil {
+			prevs[c.Source] = make(map[cadus.ChannelKey]*TimeCadu)
+		}
+		delta := c.Missing(prevs[c.Source][k])
+		sum.Update(c, delta)
+		if !c.Duplicate {
+			prevs[c.Source][k] = c
+		}
+
+		ss, ok := pending[k]
+		if !ok {
+			ss = make(map[uint32]*sighting)
+			pending[k] = ss
+		}
+		s, ok := ss[c.Sequence]
+		if !ok {
+			s = &sighting{Minute: c.Reception.Truncate(time.Minute)}
+			ss[c.Sequence] = s
+		}
+		s.Chains |= chain
+
+		if c.Reception.Sub(checked) >= grace {
+			flush(c.Reception.Add(-time.Minute - grace))
+			checked = c.Reception
+		}
+	}
+	flush(time.Now().Add(24 * time.Hour))
+	log.Printf("prime     : %d cadus, %d missing on backup", total.Prime, total.OnlyPrime)
+	log.Printf("backup    : %d cadus, %d missing on prime", total.Backup, total.OnlyBackup)
+	return sum
+}
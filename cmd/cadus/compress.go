@@ -0,0 +1,168 @@
+package main
+
+import (
+	"compress/flate"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	deflateSuffix = "+deflate"
+	zstdSuffix    = "+zstd"
+)
+
+var (
+	CompressSize  = 64 << 10
+	CompressEvery = 100 * time.Millisecond
+)
+
+func compressFlags(set *flag.FlagSet) {
+	set.IntVar(&CompressSize, "compress-size", CompressSize, "bytes written to a +deflate or +zstd destination before the compressed block is flushed")
+	set.DurationVar(&CompressEvery, "compress-every", CompressEvery, "maximum delay before the bytes written to a +deflate or +zstd destination are flushed")
+}
+
+// compressedScheme splits scheme into the scheme of the connection and the
+// suffix of the compression applied to its stream.
+func compressedScheme(scheme string) (string, string, bool) {
+	for _, s := range []string{deflateSuffix, zstdSuffix} {
+		if strings.HasSuffix(scheme, s) {
+			return strings.TrimSuffix(scheme, s), s, true
+		}
+	}
+	return scheme, "", false
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type compressConn struct {
+	net.Conn
+	codec   string
+	mu      sync.Mutex
+	w       compressor
+	in      int
+	pending int
+	out     countWriter
+	done    chan struct{}
+	once    sync.Once
+}
+
+func dialCompressed(scheme, addr string, m multicast) (net.Conn, error) {
+	base, codec, _ := compressedScheme(scheme)
+	if !strings.HasPrefix(base, "tcp") {
+		return nil, fmt.Errorf("%s: compression requires a tcp destination", scheme)
+	}
+	c, err := dial(base, addr, m)
+	if err != nil {
+		return nil, err
+	}
+	z, err := compressed(c, codec)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return z, nil
+}
+
+func compressed(c net.Conn, codec string) (*compressConn, error) {
+	z := &compressConn{Conn: c, codec: strings.TrimPrefix(codec, "+"), out: countWriter{Writer: c}, done: make(chan struct{})}
+	switch codec {
+	case deflateSuffix:
+		w, err := flate.NewWriter(&z.out, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		z.w = w
+	case zstdSuffix:
+		z.w = newZstdWriter(&z.out)
+	default:
+		return nil, fmt.Errorf("%s: unsupported compression", codec)
+	}
+	if CompressEvery > 0 {
+		go z.flushEvery(CompressEvery)
+	}
+	return z, nil
+}
+
+// Write compresses bs, flushing the compressor once CompressSize bytes are
+// pending or, from flushEvery, once they have waited for CompressEvery.
+func (c *compressConn) Write(bs []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.w.Write(bs); err != nil {
+		return 0, err
+	}
+	c.in += len(bs)
+	if c.pending += len(bs); c.pending >= CompressSize {
+		return len(bs), c.flush()
+	}
+	return len(bs), nil
+}
+
+func (c *compressConn) flush() error {
+	c.pending = 0
+	return c.w.Flush()
+}
+
+func (c *compressConn) flushEvery(every time.Duration) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			c.mu.Lock()
+			if c.pending > 0 {
+				if err := c.flush(); err != nil {
+					log.Printf("[%s] %s: %s", c.codec, c.RemoteAddr(), err)
+				}
+			}
+			c.mu.Unlock()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *compressConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	c.mu.Lock()
+	err := c.w.Close()
+	c.mu.Unlock()
+	if e := c.Conn.Close(); err == nil {
+		err = e
+	}
+	if c.out.count > 0 {
+		log.Printf("[%s] %s: %dKB compressed to %dKB (%.1f:1)", c.codec, c.RemoteAddr(), c.in>>10, c.out.count>>10, float64(c.in)/float64(c.out.count))
+	}
+	return err
+}
+
+type countWriter struct {
+	io.Writer
+	count int
+}
+
+func (w *countWriter) Write(bs []byte) (int, error) {
+	n, err := w.Writer.Write(bs)
+	w.count += n
+	return n, err
+}
+
+func decompressed(r io.Reader, codec string) io.Reader {
+	switch codec {
+	case deflateSuffix:
+		return flate.NewReader(r)
+	case zstdSuffix:
+		return newZstdReader(r)
+	default:
+		return r
+	}
+}
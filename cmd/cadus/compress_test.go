@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type bufferConn struct {
+	net.Conn
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *bufferConn) Write(bs []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(bs)
+}
+
+func (c *bufferConn) Bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.buf.Bytes()...)
+}
+
+func (c *bufferConn) Close() error {
+	return nil
+}
+
+func (c *bufferConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+// expand reads back the n first bytes written to c.
+func expand(t *testing.T, codec string, c *bufferConn, n int) []byte {
+	bs := make([]byte, n)
+	if _, err := io.ReadFull(decompressed(bytes.NewReader(c.Bytes()), codec), bs); err != nil {
+		t.Fatalf("unexpected error reading back %d bytes: %s", n, err)
+	}
+	return bs
+}
+
+func TestCompressFlush(t *testing.T) {
+	defer func(size int, every time.Duration) {
+		CompressSize, CompressEvery = size, every
+	}(CompressSize, CompressEvery)
+
+	data := bytes.Repeat([]byte("cadus"), 200)
+	for _, codec := range []string{deflateSuffix, zstdSuffix} {
+		t.Run(codec[1:]+"/size", func(t *testing.T) {
+			CompressSize, CompressEvery = 2*len(data), 0
+
+			var b bufferConn
+			c, err := compressed(&b, codec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer c.Close()
+
+			c.Write(data)
+			if _, err := decompressed(bytes.NewReader(b.Bytes()), codec).Read(make([]byte, 1)); err == nil {
+				t.Fatalf("bytes flushed below the size threshold")
+			}
+			c.Write(data)
+			if got := expand(t, codec, &b, 2*len(data)); !bytes.Equal(got, append(data, data...)) {
+				t.Fatalf("bytes flushed mismatched")
+			}
+		})
+		t.Run(codec[1:]+"/every", func(t *testing.T) {
+			CompressSize, CompressEvery = 1<<20, 10*time.Millisecond
+
+			var b bufferConn
+			c, err := compressed(&b, codec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer c.Close()
+
+			c.Write(data)
+			time.Sleep(10 * CompressEvery)
+			if got := expand(t, codec, &b, len(data)); !bytes.Equal(got, data) {
+				t.Fatalf("bytes flushed mismatched")
+			}
+		})
+	}
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+	var (
+		rs     = rand.New(rand.NewSource(1))
+		random = make([]byte, 3000)
+		cadu   = append(append([]byte{0x1a, 0xcf, 0xfc, 0x1d, 0x40, 0x5f}, bytes.Repeat([]byte{0x55}, 1000)...), 0xAB, 0xCD)
+	)
+	rs.Read(random)
+	data := []struct {
+		Name   string
+		Chunks [][]byte
+		Ratio  float64
+	}{
+		{Name: "empty"},
+		{Name: "random", Chunks: [][]byte{random, random[:100]}},
+		{Name: "idle", Chunks: [][]byte{cadu, cadu, cadu, cadu}, Ratio: 5},
+		{Name: "short-runs", Chunks: [][]byte{bytes.Repeat([]byte{1, 1, 1, 2, 2, 2}, 100)}},
+		{Name: "large", Chunks: [][]byte{bytes.Repeat([]byte{0}, 3*zstdBlockMax+10), random}, Ratio: 5},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			var (
+				buf bytes.Buffer
+				w   = newZstdWriter(&buf)
+			)
+			for _, c := range d.Chunks {
+				w.Write(c)
+				if err := w.Flush(); err != nil {
+					t.Fatalf("unexpected error flushing: %s", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("unexpected error closing: %s", err)
+			}
+			var (
+				want = bytes.Join(d.Chunks, nil)
+				size = buf.Len()
+			)
+			got, err := ioutil.ReadAll(newZstdReader(&buf))
+			if err != nil {
+				t.Fatalf("unexpected error reading: %s", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("bytes mismatched: want %d bytes, got %d", len(want), len(got))
+			}
+			if r := float64(len(want)) / float64(size); r < d.Ratio {
+				t.Errorf("%d bytes compressed to %d bytes (%.1f:1, want %.1f:1)", len(want), size, r, d.Ratio)
+			}
+		})
+	}
+}
+
+func TestZstdReaderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w := newZstdWriter(&buf)
+	w.Write(bytes.Repeat([]byte{0x55}, 100))
+	w.Close()
+	frame := buf.Bytes()
+
+	data := []struct {
+		Name  string
+		Input []byte
+		Want  error
+	}{
+		{Name: "truncated", Input: frame[:len(frame)-1], Want: io.ErrUnexpectedEOF},
+		{Name: "header", Input: frame[:5], Want: io.ErrUnexpectedEOF},
+		{Name: "magic", Input: append([]byte{0, 0, 0, 0}, frame[4:]...)},
+		{Name: "compressed", Input: append(append([]byte(nil), frame[:6]...), 0x05, 0, 0, 0)},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			_, err := ioutil.ReadAll(newZstdReader(bytes.NewReader(d.Input)))
+			if err == nil || (d.Want != nil && err != d.Want) {
+				t.Errorf("unexpected error: want %v, got %v", d.Want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var sections = []string{"input", "output", "filter", "mission"}
+
+type Config map[string]map[string]string
+
+func LoadConfig(file string) (Config, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		c       = make(Config)
+		section string
+		s       = bufio.NewScanner(r)
+	)
+	for n := 1; s.Scan(); n++ {
+		line := stripComment(s.Text())
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			if _, ok := c[section]; !ok {
+				c[section] = make(map[string]string)
+			}
+			continue
+		}
+		ix := strings.Index(line, "=")
+		if ix < 0 || section == "" {
+			return nil, fmt.Errorf("%s:%d: unexpected line %q", file, n, line)
+		}
+		key, value := strings.TrimSpace(line[:ix]), strings.TrimSpace(line[ix+1:])
+		c[section][strings.Replace(key, "_", "-", -1)] = value
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Apply sets the flags of set not given on the command line from the shared
+// sections and from the section named after the command. Options of the shared
+// sections the command does not have are skipped, unknown options of the
+// section of the command are rejected. An array sets its flag once per value.
+func (c Config) Apply(set *flag.FlagSet) ([]string, error) {
+	seen := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) {
+		seen[f.Name] = true
+	})
+
+	for s := range c {
+		if !knownSection(s) {
+			return nil, fmt.Errorf("%s: unknown section", s)
+		}
+	}
+	var args []string
+	for _, s := range append(sections, set.Name()) {
+		for k, v := range c[s] {
+			vs, err := splitArray(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", s, k, err)
+			}
+			if k == "args" {
+				args = vs
+				continue
+			}
+			if set.Lookup(k) == nil {
+				if s != set.Name() {
+					continue
+				}
+				return nil, fmt.Errorf("%s.%s: unknown option for %s", s, k, set.Name())
+			}
+			if seen[k] {
+				continue
+			}
+			for _, v := range vs {
+				if err := set.Set(k, v); err != nil {
+					return nil, fmt.Errorf("%s.%s: %v", s, k, err)
+				}
+			}
+		}
+	}
+	return args, nil
+}
+
+// commandNames are the sections of the subcommands, collected at init since
+// the subcommands themselves read the configuration.
+var commandNames []string
+
+func init() {
+	for _, c := range commands {
+		commandNames = append(commandNames, c.Name)
+	}
+}
+
+func knownSection(name string) bool {
+	for _, s := range append(sections, commandNames...) {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stripComment removes the comment of a line, a # outside of a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch b := line[i]; {
+		case quote == '"' && b == '\\':
+			i++
+		case quote != 0:
+			if b == quote {
+				quote = 0
+			}
+		case b == '"' || b == '\'':
+			quote = b
+		case b == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitArray returns the values of an array or the value itself, unquoted.
+func splitArray(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		v, err := unquote(value)
+		if err != nil {
+			return nil, err
+		}
+		return []string{v}, nil
+	}
+	var (
+		vs    []string
+		quote byte
+		ix    int
+		inner = value[1 : len(value)-1]
+	)
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) {
+			switch b := inner[i]; {
+			case quote == '"' && b == '\\':
+				i++
+				continue
+			case quote != 0:
+				if b == quote {
+					quote = 0
+				}
+				continue
+			case b == '"' || b == '\'':
+				quote = b
+				continue
+			case b != ',':
+				continue
+			}
+		} else if quote != 0 {
+			return nil, fmt.Errorf("unterminated string in %s", value)
+		}
+		if v := strings.TrimSpace(inner[ix:i]); v != "" {
+			v, err := unquote(v)
+			if err != nil {
+				return nil, err
+			}
+			vs = append(vs, v)
+		}
+		ix = i + 1
+	}
+	return vs, nil
+}
+
+func unquote(value string) (string, error) {
+	if n := len(value); n >= 2 && value[0] == '\'' && value[n-1] == '\'' {
+		return value[1 : n-1], nil
+	}
+	if strings.HasPrefix(value, "\"") {
+		return strconv.Unquote(value)
+	}
+	return value, nil
+}
+
+func parseArgs(set *flag.FlagSet, args []string) []string {
+	file := set.String("config", "", "configuration file")
+	format := set.String("time-format", "default", "format of printed times (default, iso, doy, unix or a go layout)")
+	tz := set.String("tz", "UTC", "timezone of printed times")
+	mission := set.String("mission", "", "mission profile providing defaults for frame and channel options")
+	set.Parse(args)
+
+	rest := set.Args()
+	if *file != "" {
+		c, err := LoadConfig(*file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		vs, err := c.Apply(set)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if set.NArg() == 0 && len(vs) > 0 {
+			rest = vs
+		}
+	}
+	if *mission != "" {
+		if err := applyMission(set, *mission); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if err := setTimeFormat(*format, *tz); err != nil {
+		log.Fatalln(err)
+	}
+	return rest
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type values []string
+
+func (v *values) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *values) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+const testConfig = `
+# shared options
+[input]
+p = "file" # comment
+args = ["a.cadu", "b#1.cadu"]
+
+[filter]
+vcid = 7
+dup_window = 2
+
+[list]
+m = "summary # not a comment"
+hook = ["cmd -x", 'sort, uniq', "echo \"#\""]
+
+[relay]
+alert-gap = 10
+`
+
+func loadTestConfig(t *testing.T, body string) Config {
+	file := filepath.Join(t.TempDir(), "cadus.toml")
+	if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+		t.Fatalf("unexpected error writing config: %s", err)
+	}
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("unexpected error loading config: %s", err)
+	}
+	return c
+}
+
+func TestConfigApply(t *testing.T) {
+	c := loadTestConfig(t, testConfig)
+
+	var (
+		set   = flag.NewFlagSet("list", flag.ContinueOnError)
+		proto = set.String("p", "", "")
+		vcid  = set.Int("vcid", -1, "")
+		mode  = set.String("m", "", "")
+		hooks values
+	)
+	set.Var(&hooks, "hook", "")
+	set.Parse([]string{"-vcid", "5"})
+
+	args, err := c.Apply(set)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"a.cadu", "b#1.cadu"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args mismatched: want %q, got %q", want, args)
+	}
+	if *proto != "file" {
+		t.Errorf("p mismatched: want file, got %q", *proto)
+	}
+	if *vcid != 5 {
+		t.Errorf("option given on the command line overwritten: got vcid %d", *vcid)
+	}
+	if want := "summary # not a comment"; *mode != want {
+		t.Errorf("m mismatched: want %q, got %q", want, *mode)
+	}
+	if want := (values{"cmd -x", "sort, uniq", `echo "#"`}); !reflect.DeepEqual(hooks, want) {
+		t.Errorf("hook mismatched: want %q, got %q", want, hooks)
+	}
+}
+
+func TestConfigSections(t *testing.T) {
+	data := []struct {
+		Name   string
+		Config string
+		Err    bool
+	}{
+		{Name: "other-command", Config: "[relay]\nalert-gap = 10\n"},
+		{Name: "shared", Config: "[filter]\ndup-window = 2\n"},
+		{Name: "unknown-option", Config: "[list]\nalert-gap = 10\n", Err: true},
+		{Name: "unknown-section", Config: "[lists]\nm = \"summary\"\n", Err: true},
+		{Name: "unterminated", Config: "[list]\nm = \"summary\n", Err: true},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			set := flag.NewFlagSet("list", flag.ContinueOnError)
+			set.String("m", "", "")
+			_, err := loadTestConfig(t, d.Config).Apply(set)
+			if d.Err && err == nil {
+				t.Fatalf("config applied without error")
+			}
+			if !d.Err && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
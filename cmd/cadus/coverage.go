@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+type coverageRow struct {
+	Day      string  `json:"day"`
+	Channel  string  `json:"channel"`
+	Expected int     `json:"expected"`
+	Received int     `json:"received"`
+	Missing  uint32  `json:"missing"`
+	Percent  float64 `json:"missing_percent"`
+}
+
+func printCoverage(queue <-chan *TimeCadu, export string) Summary {
+	const line = "%s | %-10s | %10d expected | %10d received | %8d missing | %6.2f%%"
+
+	var (
+		prevs = make(map[cadus.ChannelKey]*TimeCadu)
+		days  = make(map[time.Time]map[cadus.ChannelKey]*Stats)
+		sum   Summary
+	)
+	for c := range queue {
+		k := c.Key()
+		delta := c.Missing(prevs[k])
+		sum.Update(c, delta)
+
+		d := boundary(c.Reception, "day")
+		if days[d] == nil {
+			days[d] = make(map[cadus.ChannelKey]*Stats)
+		}
+		s, ok := days[d][k]
+		if !ok {
+			s = &Stats{}
+			days[d][k] = s
+		}
+		s.Update(c, delta)
+		if !c.Duplicate {
+			prevs[k] = c
+		}
+	}
+
+	ds := make([]time.Time, 0, len(days))
+	for d := range days {
+		ds = append(ds, d)
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Before(ds[j]) })
+
+	var report []coverageRow
+	for _, d := range ds {
+		keys := make([]cadus.ChannelKey, 0, len(days[d]))
+		for k := range days[d] {
+			keys = append(keys, k)
+		}
+		cadus.SortKeys(keys)
+		for _, k := range keys {
+			s := days[d][k]
+			r := coverageRow{
+				Day:      d.Format("2006-01-02"),
+				Channel:  k.String(),
+				Expected: s.Expected(),
+				Received: s.Count - s.Duplicate,
+				Missing:  s.Missing,
+				Percent:  s.Loss(),
+			}
+			rows.Printf(line, r.Day, r.Channel, r.Expected, r.Received, r.Missing, r.Percent)
+			report = append(report, r)
+		}
+	}
+	if export != "" {
+		if err := writeJSON(export, report); err != nil {
+			log.Println(err)
+		}
+	}
+	return sum
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"net"
+)
+
+type dryconn struct {
+	net.Conn
+	name   string
+	writes int
+	bytes  int
+}
+
+func dryRun(name string) *dryconn {
+	log.Printf("[dry-run] %s: output disabled", name)
+	return &dryconn{name: name}
+}
+
+func (d *dryconn) Write(bs []byte) (int, error) {
+	d.writes++
+	d.bytes += len(bs)
+	return len(bs), nil
+}
+
+func (d *dryconn) Close() error {
+	log.Printf("[dry-run] %s: %d writes, %d bytes would have been written", d.name, d.writes, d.bytes)
+	return nil
+}
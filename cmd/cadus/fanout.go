@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+const fanoutQueue = 1024
+
+type destination struct {
+	Scheme    string
+	Addr      string
+	Multicast multicast
+	Rate      bitrate
+	Burst     int
+}
+
+func parseDestination(str, scheme string, m multicast) (destination, error) {
+	d := destination{Scheme: scheme, Addr: str, Multicast: m, Burst: 1}
+	u, err := url.Parse(str)
+	switch {
+	case err != nil:
+		return d, nil
+	case u.Host != "":
+		d.Scheme, d.Addr = u.Scheme, u.Host
+	case u.Scheme != "" && u.Opaque == "" && u.Path != "":
+		d.Scheme, d.Addr = u.Scheme, u.Path
+	default:
+		return d, nil
+	}
+
+	q := u.Query()
+	if v := q.Get("ttl"); v != "" {
+		if d.Multicast.TTL, err = strconv.Atoi(v); err != nil {
+			return d, fmt.Errorf("%s: invalid ttl %s", str, v)
+		}
+	}
+	if v := q.Get("ifname"); v != "" {
+		d.Multicast.Ifname = v
+	}
+	if v := q.Get("src"); v != "" {
+		d.Multicast.Source = v
+	}
+	if v := q.Get("rate"); v != "" {
+		if err := d.Rate.Set(v); err != nil {
+			return d, fmt.Errorf("%s: %v", str, err)
+		}
+	}
+	if v := q.Get("burst"); v != "" {
+		if d.Burst, err = strconv.Atoi(v); err != nil {
+			return d, fmt.Errorf("%s: invalid burst %s", str, v)
+		}
+	}
+	return d, nil
+}
+
+func (d destination) String() string {
+	return d.Scheme + "://" + d.Addr
+}
+
+func (d destination) Pacer() *Pacer {
+	if d.Rate <= 0 {
+		return nil
+	}
+	return NewPacer(float64(d.Rate), d.Burst)
+}
+
+type output struct {
+	name  string
+	w     io.Writer
+	c     io.Closer
+	pacer *Pacer
+	queue chan []byte
+
+	sent    int
+	dropped int
+	failed  int
+}
+
+type fanout struct {
+	outputs []*output
+	wg      sync.WaitGroup
+}
+
+func (f *fanout) Add(name string, w io.Writer, c io.Closer, p *Pacer) {
+	o := &output{
+		name:  name,
+		w:     w,
+		c:     c,
+		pacer: p,
+		queue: make(chan []byte, fanoutQueue),
+	}
+	f.outputs = append(f.outputs, o)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		for bs := range o.queue {
+			if o.pacer != nil {
+				o.pacer.Wait(len(bs))
+			}
+			if _, err := o.w.Write(bs); err != nil {
+				o.failed++
+			} else {
+				o.sent++
+			}
+		}
+	}()
+}
+
+func (f *fanout) Write(bs []byte) (int, error) {
+	vs := make([]byte, len(bs))
+	copy(vs, bs)
+	for _, o := range f.outputs {
+		if len(f.outputs) == 1 {
+			o.queue <- vs
+			continue
+		}
+		select {
+		case o.queue <- vs:
+		default:
+			o.dropped++
+		}
+	}
+	return len(bs), nil
+}
+
+func (f *fanout) Close() int {
+	for _, o := range f.outputs {
+		close(o.queue)
+	}
+	f.wg.Wait()
+
+	var failed int
+	for _, o := range f.outputs {
+		o.c.Close()
+		failed += o.failed
+		if len(f.outputs) > 1 {
+			log.Printf("[output] %s: %d sent, %d dropped, %d failed", o.name, o.sent, o.dropped, o.failed)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestParseDestination(t *testing.T) {
+	data := []struct {
+		Input string
+		Want  destination
+		Error bool
+	}{
+		{Input: "127.0.0.1:10015", Want: destination{Scheme: "udp", Addr: "127.0.0.1:10015", Burst: 1}},
+		{Input: "tcp://127.0.0.1:10015", Want: destination{Scheme: "tcp", Addr: "127.0.0.1:10015", Burst: 1}},
+		{Input: "tcp+zstd://host:10015", Want: destination{Scheme: "tcp+zstd", Addr: "host:10015", Burst: 1}},
+		{Input: "rt:/var/rt", Want: destination{Scheme: "rt", Addr: "/var/rt", Burst: 1}},
+		{
+			Input: "udp://239.192.0.1:10015?ttl=4&ifname=eth1&src=10.0.0.1",
+			Want:  destination{Scheme: "udp", Addr: "239.192.0.1:10015", Multicast: multicast{TTL: 4, Ifname: "eth1", Source: "10.0.0.1"}, Burst: 1},
+		},
+		{Input: "udp://host:10015?rate=2Mbps&burst=8", Want: destination{Scheme: "udp", Addr: "host:10015", Rate: 2e6, Burst: 8}},
+		{Input: "udp://host:10015?ttl=x", Error: true},
+		{Input: "udp://host:10015?rate=-1", Error: true},
+		{Input: "udp://host:10015?burst=many", Error: true},
+	}
+	for _, d := range data {
+		t.Run(d.Input, func(t *testing.T) {
+			got, err := parseDestination(d.Input, "udp", multicast{})
+			if d.Error {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != d.Want {
+				t.Errorf("want %+v, got %+v", d.Want, got)
+			}
+		})
+	}
+}
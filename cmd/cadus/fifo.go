@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+const fifoPoll = 100 * time.Millisecond
+
+type fifoReader struct {
+	*os.File
+	ctx context.Context
+}
+
+func followFIFO(ctx context.Context, f *os.File) io.Reader {
+	if f == os.Stdin {
+		return f
+	}
+	if i, err := f.Stat(); err != nil || i.Mode()&os.ModeNamedPipe == 0 {
+		return f
+	}
+	return &fifoReader{File: f, ctx: ctx}
+}
+
+func (r *fifoReader) Read(bs []byte) (int, error) {
+	for {
+		n, err := r.File.Read(bs)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		select {
+		case <-r.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(fifoPoll):
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/busoc/cadus"
+)
+
+type fillKey struct {
+	Space   uint16
+	Channel uint8
+}
+
+type fillStats struct {
+	Count   int
+	Filled  int
+	Missing int
+}
+
+func runFill(args []string) {
+	set := flag.NewFlagSet("fill", flag.ExitOnError)
+	file := set.String("o", "", "write the merged cadus to file")
+	dry := set.Bool("n", false, "report what would be written to the output file without writing it")
+	profileFlags(set)
+	args = parseArgs(set, args)
+
+	if len(args) < 2 {
+		log.Fatalln("fill: realtime archive and at least one playback archive required")
+	}
+	if *file == "" {
+		log.Fatalln("fill: output file required")
+	}
+	if err := Profile.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	replay, err := indexPlayback(args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var w io.WriteCloser
+	if *dry {
+		w = dryRun(*file)
+	} else if w, err = os.Create(*file); err != nil {
+		log.Fatalln(err)
+	}
+	defer w.Close()
+	ws := bufio.NewWriter(w)
+	defer ws.Flush()
+
+	r, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer r.Close()
+
+	const line = "%-10s | %8d | %8d | %6d filled | %6d missing"
+	var (
+		rs    = bufio.NewReader(r)
+		prevs = make(map[cadus.ChannelKey]*cadus.Cadu)
+		stats = make(map[cadus.ChannelKey]*fillStats)
+	)
+	for {
+		c, err := Profile.Decode(rs)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("%s: %s", args[0], err)
+			}
+			break
+		}
+		k := c.Key()
+		s, ok := stats[k]
+		if !ok {
+			s = &fillStats{}
+			stats[k] = s
+		}
+		if prev := prevs[k]; prev != nil {
+			mask := c.Profile().CounterMask()
+			if delta := c.Missing(prev); delta > 0 {
+				var filled int
+				for i := uint32(1); i <= delta; i++ {
+					p, ok := replay[fillKey{Space: k.Space, Channel: k.Channel}][(prev.Sequence+i)&mask]
+					if !ok {
+						continue
+					}
+					ws.Write(p.Bytes())
+					filled++
+				}
+				s.Filled += filled
+				s.Missing += int(delta) - filled
+				rows.Printf(line, k, prev.Sequence, c.Sequence, filled, int(delta)-filled)
+			}
+		}
+		ws.Write(c.Bytes())
+		s.Count++
+		if prev := prevs[k]; prev == nil || c.Sequence != prev.Sequence {
+			prevs[k] = c
+		}
+	}
+
+	keys := make([]cadus.ChannelKey, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	cadus.SortKeys(keys)
+	var z fillStats
+	for _, k := range keys {
+		s := stats[k]
+		log.Printf("%-10s: %d cadus, %d filled from playback, %d still missing", k, s.Count, s.Filled, s.Missing)
+		z.Count, z.Filled, z.Missing = z.Count+s.Count, z.Filled+s.Filled, z.Missing+s.Missing
+	}
+	log.Printf("%d cadus, %d filled from playback, %d still missing", z.Count, z.Filled, z.Missing)
+}
+
+func indexPlayback(paths []string) (map[fillKey]map[uint32]*cadus.Cadu, error) {
+	index := make(map[fillKey]map[uint32]*cadus.Cadu)
+	for _, p := range paths {
+		r, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		rs := bufio.NewReader(r)
+		for {
+			c, err := Profile.Decode(rs)
+			if err != nil {
+				if err != io.EOF {
+					log.Printf("%s: %s", p, err)
+				}
+				break
+			}
+			if c.Error != nil {
+				continue
+			}
+			k := fillKey{Space: c.Space, Channel: c.Channel}
+			if index[k] == nil {
+				index[k] = make(map[uint32]*cadus.Cadu)
+			}
+			index[k][c.Sequence] = c
+		}
+		r.Close()
+	}
+	return index, nil
+}
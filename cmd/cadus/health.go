@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+const rateEvery = 10 * time.Second
+
+type Health struct {
+	mu        sync.Mutex
+	timeout   time.Duration
+	started   time.Time
+	last      time.Time
+	count     int
+	missing   uint64
+	corrupted int
+	rate      float64
+	errs      map[string]error
+	stalled   map[string]time.Time
+}
+
+func NewHealth(timeout time.Duration) *Health {
+	return &Health{
+		timeout: timeout,
+		started: time.Now(),
+		errs:    make(map[string]error),
+		stalled: make(map[string]time.Time),
+	}
+}
+
+func (h *Health) Watch(ctx context.Context, queue <-chan *TimeCadu) <-chan *TimeCadu {
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+
+		var (
+			prevs = make(map[cadus.ChannelKey]*cadus.Cadu)
+			since = time.Now()
+			count int
+		)
+		for c := range queue {
+			k := c.Key()
+			p, ok := prevs[k]
+			if !ok {
+				hdr := cadus.Header{Space: k.Space, Channel: k.Channel, Replay: k.Replay}
+				p = &cadus.Cadu{Header: &hdr}
+				prevs[k] = p
+			}
+			now := time.Now()
+
+			h.mu.Lock()
+			h.last = now
+			h.count++
+			if ok {
+				h.missing += uint64(c.Cadu.Missing(p))
+			}
+			if c.Error != nil {
+				h.corrupted++
+			}
+			if count++; now.Sub(since) >= rateEvery {
+				h.rate = float64(count) / now.Sub(since).Seconds()
+				since, count = now, 0
+			}
+			h.mu.Unlock()
+
+			p.Sequence = c.Sequence
+			if !send(ctx, q, c) {
+				return
+			}
+		}
+	}()
+	return q
+}
+
+func (h *Health) Report(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		delete(h.errs, name)
+	} else {
+		h.errs[name] = err
+	}
+}
+
+func (h *Health) Stalled(name string, stalled bool) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if stalled {
+		h.stalled[name] = time.Now()
+	} else {
+		delete(h.stalled, name)
+	}
+}
+
+func (h *Health) Writer(name string, w io.Writer) io.Writer {
+	return &healthWriter{Writer: w, name: name, health: h}
+}
+
+type healthReport struct {
+	Status    string               `json:"status"`
+	Flowing   bool                 `json:"flowing"`
+	Count     int                  `json:"count"`
+	Missing   uint64               `json:"missing"`
+	Corrupted int                  `json:"corrupted"`
+	Rate      float64              `json:"rate"`
+	Last      *time.Time           `json:"last,omitempty"`
+	Outputs   map[string]string    `json:"outputs,omitempty"`
+	Stalled   map[string]time.Time `json:"stalled,omitempty"`
+}
+
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	since := h.last
+	if since.IsZero() {
+		since = h.started
+	}
+	z := healthReport{
+		Status:    "ok",
+		Flowing:   time.Since(since) <= h.timeout,
+		Count:     h.count,
+		Missing:   h.missing,
+		Corrupted: h.corrupted,
+		Rate:      h.rate,
+	}
+	if !z.Flowing {
+		z.Rate = 0
+	}
+	if !h.last.IsZero() {
+		z.Last = &h.last
+	}
+	if len(h.errs) > 0 {
+		z.Outputs = make(map[string]string)
+		for n, err := range h.errs {
+			z.Outputs[n] = err.Error()
+		}
+	}
+	if len(h.stalled) > 0 {
+		z.Stalled = make(map[string]time.Time)
+		for n, t := range h.stalled {
+			z.Stalled[n] = t
+		}
+	}
+	code := http.StatusOK
+	if !z.Flowing || len(z.Outputs) > 0 {
+		z.Status, code = "failing", http.StatusServiceUnavailable
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(z)
+}
+
+func serveHealth(addr string, h *Health) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", h)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Fatalln(err)
+		}
+	}()
+}
+
+type healthWriter struct {
+	io.Writer
+	name   string
+	health *Health
+}
+
+func (w *healthWriter) Write(bs []byte) (int, error) {
+	n, err := w.Writer.Write(bs)
+	w.health.Report(w.name, err)
+	return n, err
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+var (
+	Hooks     = cadus.NewPipeline()
+	HookSpecs hookList
+
+	hookClosers []func()
+)
+
+func hookFlags(set *flag.FlagSet) {
+	set.Var(&HookSpecs, "hook", "command plugged in the pipeline, repeatable: exec:cmd (cadus written to its stdin), exec-frame:cmd (frames written to its stdin), filter:cmd (cadus written to its stdin, kept when it answers a non-zero byte)")
+}
+
+const hookTimeout = 5 * time.Second
+
+const (
+	hookExec      = "exec"
+	hookExecFrame = "exec-frame"
+	hookFilter    = "filter"
+)
+
+type hookList []string
+
+func (h *hookList) String() string {
+	return strings.Join(*h, ",")
+}
+
+func (h *hookList) Set(str string) error {
+	if _, _, err := splitHook(str); err != nil {
+		return err
+	}
+	*h = append(*h, str)
+	return nil
+}
+
+func splitHook(str string) (string, string, error) {
+	x := strings.Index(str, ":")
+	if x < 0 || strings.TrimSpace(str[x+1:]) == "" {
+		return "", "", fmt.Errorf("%s: hook should be given as kind:command", str)
+	}
+	switch kind := str[:x]; kind {
+	case hookExec, hookExecFrame, hookFilter:
+		return kind, str[x+1:], nil
+	default:
+		return "", "", fmt.Errorf("%s: unsupported hook (exec, exec-frame, filter)", kind)
+	}
+}
+
+// loadHooks starts the commands given with -hook and registers them in Hooks.
+// The hooks are loaded once whatever the number of sources opened.
+func loadHooks(ctx context.Context) error {
+	specs := HookSpecs
+	HookSpecs = nil
+	for _, s := range specs {
+		kind, cmd, err := splitHook(s)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case hookExec:
+			f, err := startObserver(ctx, cmd)
+			if err != nil {
+				return err
+			}
+			Hooks.Observe(func(c *cadus.Cadu) { f(c.Bytes()) })
+		case hookExecFrame:
+			f, err := startObserver(ctx, cmd)
+			if err != nil {
+				return err
+			}
+			Hooks.ObserveFrame(f)
+		case hookFilter:
+			f, err := startFilter(ctx, cmd)
+			if err != nil {
+				return err
+			}
+			Hooks.Filter(func(c *cadus.Cadu) bool { return f(c.Bytes()) })
+		}
+	}
+	return nil
+}
+
+// startObserver runs cmd and returns a function queueing the bytes given to
+// be written to its stdin. Bytes are dropped rather than stalling the pipeline
+// when cmd does not keep up.
+func startObserver(ctx context.Context, cmd string) (func([]byte), error) {
+	c := exec.CommandContext(ctx, "sh", "-c", cmd)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	w, err := c.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	var (
+		queue = make(chan []byte, 1024)
+		done  = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		defer c.Wait()
+		defer w.Close()
+
+		ws := bufio.NewWriter(w)
+		for bs := range queue {
+			if _, err := ws.Write(bs); err != nil {
+				log.Printf("[hook] %s: %s", cmd, err)
+				break
+			}
+			if len(queue) == 0 {
+				if err := ws.Flush(); err != nil {
+					log.Printf("[hook] %s: %s", cmd, err)
+					break
+				}
+			}
+		}
+		ws.Flush()
+		for range queue {
+		}
+	}()
+	hookClosers = append(hookClosers, func() {
+		close(queue)
+		select {
+		case <-done:
+		case <-time.After(hookTimeout):
+			log.Printf("[hook] %s: still running, not waited for", cmd)
+		}
+	})
+	var dropped int
+	return func(bs []byte) {
+		select {
+		case queue <- append([]byte(nil), bs...):
+		default:
+			if dropped++; dropped == 1 || dropped%1000 == 0 {
+				log.Printf("[hook] %s: %d writes dropped", cmd, dropped)
+			}
+		}
+	}, nil
+}
+
+// startFilter runs cmd and returns a function writing the bytes given to its
+// stdin then reading back the byte deciding whether they are kept. Everything
+// is kept once cmd has failed.
+func startFilter(ctx context.Context, cmd string) (func([]byte) bool, error) {
+	c := exec.CommandContext(ctx, "sh", "-c", cmd)
+	c.Stderr = os.Stderr
+	w, err := c.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	var (
+		rs     = bufio.NewReader(r)
+		ws     = bufio.NewWriter(w)
+		failed bool
+	)
+	hookClosers = append(hookClosers, func() {
+		if !failed {
+			w.Close()
+			c.Wait()
+		}
+	})
+	return func(bs []byte) bool {
+		if failed {
+			return true
+		}
+		ws.Write(bs)
+		err := ws.Flush()
+		if err == nil {
+			var b byte
+			if b, err = rs.ReadByte(); err == nil {
+				return b != 0
+			}
+		}
+		if err == io.EOF {
+			err = fmt.Errorf("command exited")
+		}
+		log.Printf("[hook] %s: %s (all cadus kept from now on)", cmd, err)
+		failed = true
+		w.Close()
+		go c.Wait()
+		return true
+	}, nil
+}
+
+// closeHooks closes the stdin of the hook commands and waits for them to
+// exit, giving up on the observers still running after hookTimeout.
+func closeHooks() {
+	for _, f := range hookClosers {
+		f()
+	}
+	hookClosers = nil
+}
+
+func withHooks(ctx context.Context, queue <-chan *TimeCadu) <-chan *TimeCadu {
+	if Hooks.Len() == 0 {
+		return queue
+	}
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+		for c := range queue {
+			cdu, ok := Hooks.Cadu(c.Cadu)
+			if !ok {
+				c.Release()
+				continue
+			}
+			c.Cadu = cdu
+			if !send(ctx, q, c) {
+				return
+			}
+		}
+	}()
+	return q
+}
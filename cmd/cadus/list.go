@@ -0,0 +1,1164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+const (
+	ExitMissing = 1 << (iota + 2)
+	ExitCorrupted
+	ExitSource
+)
+
+var rows = log.New(os.Stdout, "", 0)
+
+type Stats struct {
+	Count     int
+	Missing   uint32
+	Corrupted int
+	NoStart   int
+	Idle      int
+	Duplicate int
+	Resumed   int
+	Backward  int
+}
+
+func (s Stats) String() string {
+	str := fmt.Sprintf("%d cadus (%d missing, %d corrupted, %d duplicated, %d nostart, %d idle)", s.Count, s.Missing, s.Corrupted, s.Duplicate, s.NoStart, s.Idle)
+	if s.Resumed > 0 {
+		str += fmt.Sprintf(" - %d discontinuities", s.Resumed)
+	}
+	if s.Backward > 0 {
+		str += fmt.Sprintf(" - %d backward jumps", s.Backward)
+	}
+	return str
+}
+
+func (s *Stats) Update(c *TimeCadu, delta uint32) {
+	s.Count++
+	s.Missing += delta
+	if c.Error != nil {
+		s.Corrupted++
+	}
+	if c.Duplicate {
+		s.Duplicate++
+	}
+	if c.Resumed {
+		s.Resumed++
+	}
+	if c.Backward > 0 {
+		s.Backward++
+	}
+	switch c.Pointer() {
+	case cadus.PointerNoStart:
+		s.NoStart++
+	case cadus.PointerIdle:
+		s.Idle++
+	}
+}
+
+func (s Stats) Expected() int {
+	return s.Count - s.Duplicate + int(s.Missing)
+}
+
+func (s Stats) Completeness() float64 {
+	expected := s.Expected()
+	if expected == 0 {
+		return 0
+	}
+	return float64(s.Count-s.Duplicate) * 100 / float64(expected)
+}
+
+func (s Stats) Loss() float64 {
+	expected := s.Expected()
+	if expected == 0 {
+		return 0
+	}
+	return float64(s.Missing) * 100 / float64(expected)
+}
+
+func (s Stats) FER() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Corrupted) * 100 / float64(s.Count)
+}
+
+type quality struct {
+	Count        int     `json:"count"`
+	Expected     int     `json:"expected"`
+	Missing      uint32  `json:"missing"`
+	Corrupted    int     `json:"corrupted"`
+	Duplicated   int     `json:"duplicated"`
+	FER          float64 `json:"fer"`
+	Loss         float64 `json:"loss"`
+	Completeness float64 `json:"completeness"`
+}
+
+func (s Stats) Quality() quality {
+	return quality{
+		Count:        s.Count,
+		Expected:     s.Expected(),
+		Missing:      s.Missing,
+		Corrupted:    s.Corrupted,
+		Duplicated:   s.Duplicate,
+		FER:          s.FER(),
+		Loss:         s.Loss(),
+		Completeness: s.Completeness(),
+	}
+}
+
+type distribution struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// sketchGamma is the ratio between the bounds of the buckets of a sketch,
+// the percentiles it reports being within 1% of the exact ones.
+const sketchGamma = 1.02
+
+// sketch summarizes a stream of values in constant memory: the extremes and
+// the moments are exact while the percentiles are read from logarithmic
+// buckets, of which a few thousands at most cover the durations and sizes seen.
+type sketch struct {
+	count    int
+	min, max float64
+	mean, m2 float64
+	zeros    int
+	buckets  map[int]int
+}
+
+func (s *sketch) Add(v float64) {
+	if s.count++; s.count == 1 || v < s.min {
+		s.min = v
+	}
+	if s.count == 1 || v > s.max {
+		s.max = v
+	}
+	delta := v - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (v - s.mean)
+
+	if v <= 0 {
+		s.zeros++
+		return
+	}
+	if s.buckets == nil {
+		s.buckets = make(map[int]int)
+	}
+	s.buckets[int(math.Floor(math.Log(v)/math.Log(sketchGamma)))]++
+}
+
+func (s *sketch) Mean() float64 {
+	return s.mean
+}
+
+func (s *sketch) Stddev() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return math.Sqrt(s.m2 / float64(s.count))
+}
+
+func (s *sketch) Percentile(p float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(s.count)))
+	if rank <= s.zeros {
+		return s.min
+	}
+	ix := make([]int, 0, len(s.buckets))
+	for i := range s.buckets {
+		ix = append(ix, i)
+	}
+	sort.Ints(ix)
+	n := s.zeros
+	for _, i := range ix {
+		if n += s.buckets[i]; n >= rank {
+			v := 2 * math.Pow(sketchGamma, float64(i+1)) / (sketchGamma + 1)
+			return math.Max(s.min, math.Min(s.max, v))
+		}
+	}
+	return s.max
+}
+
+func (s *sketch) Distribution() distribution {
+	if s.count == 0 {
+		return distribution{}
+	}
+	return distribution{
+		Count: s.count,
+		P50:   s.Percentile(50),
+		P95:   s.Percentile(95),
+		P99:   s.Percentile(99),
+		Max:   s.max,
+	}
+}
+
+func (d distribution) Durations() string {
+	s := func(f float64) time.Duration { return time.Duration(f * float64(time.Second)) }
+	return fmt.Sprintf("%8d | p50: %12s | p95: %12s | p99: %12s | max: %12s", d.Count, s(d.P50), s(d.P95), s(d.P99), s(d.Max))
+}
+
+func (d distribution) String() string {
+	return fmt.Sprintf("%8d | p50: %12.0f | p95: %12.0f | p99: %12.0f | max: %12.0f", d.Count, d.P50, d.P95, d.P99, d.Max)
+}
+
+type summaryReport struct {
+	Start        time.Time          `json:"start"`
+	End          time.Time          `json:"end"`
+	Bitrate      float64            `json:"bitrate"`
+	Total        quality            `json:"total"`
+	Realtime     quality            `json:"realtime"`
+	Playback     quality            `json:"playback"`
+	Channels     map[string]quality `json:"channels"`
+	GapSizes     distribution       `json:"gap_sizes"`
+	GapDurations distribution       `json:"gap_durations"`
+	Intervals    distribution       `json:"intervals"`
+}
+
+type Summary struct {
+	Realtime Stats
+	Playback Stats
+}
+
+func (s *Summary) Update(c *TimeCadu, delta uint32) {
+	if c.Replay {
+		s.Playback.Update(c, delta)
+	} else {
+		s.Realtime.Update(c, delta)
+	}
+}
+
+func (s Summary) Total() Stats {
+	return Stats{
+		Count:     s.Realtime.Count + s.Playback.Count,
+		Missing:   s.Realtime.Missing + s.Playback.Missing,
+		Corrupted: s.Realtime.Corrupted + s.Playback.Corrupted,
+		NoStart:   s.Realtime.NoStart + s.Playback.NoStart,
+		Idle:      s.Realtime.Idle + s.Playback.Idle,
+		Duplicate: s.Realtime.Duplicate + s.Playback.Duplicate,
+		Resumed:   s.Realtime.Resumed + s.Playback.Resumed,
+		Backward:  s.Realtime.Backward + s.Playback.Backward,
+	}
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("realtime: %s - playback: %s", s.Realtime, s.Playback)
+}
+
+func runList(args []string) {
+	set := flag.NewFlagSet("list", flag.ExitOnError)
+	proto := set.String("p", "udp", "protocol")
+	mode := set.String("m", "", "mode")
+	mark := set.String("mark", "", "mark hour or day boundaries in gaps mode (hour, day)")
+	manifest := set.String("manifest", "", "write the missing sequence ranges as json to file in gaps mode")
+	replay := replayFlags(set)
+	silence := set.Duration("silence", 30*time.Second, "interval without cadus ending a pass in passes mode")
+	export := set.String("export", "", "write the summary, link quality indicators and distributions (durations in seconds) as json to file in summary mode, or the day by channel coverage in coverage mode")
+	grace := set.Duration("grace", 10*time.Second, "delay before a minute is reported in compare mode")
+	hrdfe := set.Bool("hrdfe", false, "skip byte")
+	interval := set.Duration("i", 0, "interim summary interval")
+	columns := set.String("columns", DefaultColumns, "columns printed in list mode")
+	quiet := set.Bool("q", false, "suppress per-frame output and exit non-zero when thresholds are exceeded")
+	check := checkFlags(set)
+	size := set.Int("dup-window", 1, "number of previous sequences per channel checked for duplicates")
+	dups := set.Bool("dups", false, "list duplicated cadus")
+	file := set.String("o", "", "write per-frame output to file (-: write the raw cadus to stdout and everything else to stderr)")
+	limit := set.Int64("rotate-size", 0, "rotate output file after size bytes")
+	every := set.Duration("rotate-every", 0, "rotate output file after interval")
+	health := set.String("health", "", "serve health report on address")
+	stale := set.Duration("health-timeout", 10*time.Second, "maximum interval without cadus before reporting unhealthy")
+	logLimit := set.Int("log-limit", DefaultLogLimit, "identical error lines logged per interval before aggregating (0: no limit)")
+	logEvery := set.Duration("log-every", DefaultLogEvery, "interval over which identical error lines are aggregated")
+	alerts := alertFlags(set)
+	profileFlags(set)
+	scanFlags(set)
+	keyFlags(set)
+	popts := packetFlags(set)
+	clockFlags(set)
+	set.BoolVar(&KernelTime, "kernel-time", KernelTime, "timestamp udp cadus with kernel receive times")
+	set.StringVar(&NameTime, "time-from-name", NameTime, "strftime pattern of the capture start time in file names, spanning directories when it contains / (e.g. %Y%m%d_%H%M%S, rt: rolling rt archives)")
+	set.Var(&NameRate, "time-from-name-rate", "nominal bitrate of the files, spacing the times of their cadus (e.g. 50Mbps)")
+	fopts := filterFlags(set)
+	pick := set.Duration("select", 0, "with redundant inputs, keep per channel and sequence the first crc-valid copy, waiting up to the duration for one before keeping a corrupted copy")
+	joinFlags(set, "")
+	recordFlags(set)
+	hookFlags(set)
+	clientFlags(set)
+	args = parseArgs(set, args)
+
+	hc := NewHealth(*stale)
+	if *file == "-" {
+		log.SetOutput(os.Stderr)
+	}
+	st, err := newTracker()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	switch {
+	case *quiet:
+		rows.SetOutput(ioutil.Discard)
+	case *file == "-":
+		rows.SetOutput(os.Stderr)
+	case *file != "":
+		w, err := st.Output(*file, *limit, *every)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer w.Close()
+		reopenOnSignal(w)
+		rows.SetOutput(hc.Writer(*file, w))
+	}
+
+	cs, err := parseColumns(*columns)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if *mode == "compare" && (len(args) != 2 || !strings.Contains(args[0], "://") || !strings.Contains(args[1], "://")) {
+		log.Fatalln("compare: prime and backup inputs required as urls")
+	}
+	queue, errc, err := openSource(ctx, *proto, args, *hrdfe)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	failures := watchErrors(errc, hc)
+	alerts.Health = hc
+	queue = fopts.Filter(ctx, queue)
+	if *pick > 0 {
+		queue = selectBest(ctx, queue, *pick)
+	}
+	queue = alerts.Watch(ctx, queue)
+	if *health != "" {
+		queue = hc.Watch(ctx, queue)
+		serveHealth(*health, hc)
+	}
+	if *size > 0 && *mode != "compare" {
+		queue = markDuplicates(ctx, queue, *size, *dups)
+	}
+	queue = markJumps(ctx, queue)
+	if *file == "-" {
+		queue = writeRaw(ctx, queue, os.Stdout)
+	}
+	if *interval > 0 {
+		queue = summarize(ctx, queue, *interval)
+	}
+	queue = st.Track(ctx, queue)
+
+	var z Summary
+	switch *mode {
+	case "", "list":
+		z = printCadus(queue, cs)
+	case "gaps":
+		z = printGaps(queue, *mark, *manifest, replay)
+	case "jitter":
+		z = printJitter(queue)
+	case "summary":
+		z = printSummary(queue, *export)
+	case "apid":
+		z = printPackets(queue, popts)
+	case "coverage":
+		z = printCoverage(queue, *export)
+	case "passes":
+		z = printPasses(queue, *silence)
+	case "compare":
+		z = printCompare(queue, args[0], *grace)
+	case "verify":
+		z = verifyCadus(queue, newRateLogger(rows, *logLimit, *logEvery))
+	default:
+		log.Fatalf("unknown working mode %q", *mode)
+	}
+	if err := st.Close(); err != nil {
+		log.Println(err)
+	}
+	closeHooks()
+	if err := Recorder.Close(); err != nil {
+		log.Println(err)
+	}
+	var code int
+	if check.enabled(*quiet) {
+		code = check.Check(z, args)
+	}
+	alerts.Close()
+	if <-failures > 0 {
+		code |= ExitSource
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+func writeJSON(file string, v interface{}) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(bs, '\n'), 0644)
+}
+
+type jsonArray struct {
+	file  *os.File
+	w     *bufio.Writer
+	count int
+}
+
+func createJSONArray(file string) (*jsonArray, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonArray{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (a *jsonArray) Write(v interface{}) error {
+	bs, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if a.count == 0 {
+		sep = "[\n  "
+	}
+	a.count++
+	a.w.WriteString(sep)
+	_, err = a.w.Write(bs)
+	return err
+}
+
+func (a *jsonArray) Close() error {
+	end := "\n]\n"
+	if a.count == 0 {
+		end = "[]\n"
+	}
+	a.w.WriteString(end)
+	err := a.w.Flush()
+	if e := a.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+type rotater struct {
+	file    string
+	size    int64
+	every   time.Duration
+	written int64
+	when    time.Time
+
+	mu    sync.Mutex
+	inner *os.File
+}
+
+func Rotate(file string, size int64, every time.Duration) (*rotater, error) {
+	return RotateFrom(file, size, every, 0)
+}
+
+// RotateFrom opens file like Rotate but keeps its first offset bytes and
+// appends after them instead of truncating it.
+func RotateFrom(file string, size int64, every time.Duration, offset int64) (*rotater, error) {
+	r := &rotater{file: file, size: size, every: every}
+	if offset <= 0 {
+		if err := r.open(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if i, err := f.Stat(); err == nil && i.Size() < offset {
+		offset = i.Size()
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.inner, r.written, r.when = f, offset, time.Now()
+	return r, nil
+}
+
+func (r *rotater) Write(bs []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	full := r.size > 0 && r.written+int64(len(bs)) > r.size
+	if full || (r.every > 0 && time.Since(r.when) >= r.every) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.inner.Write(bs)
+	r.written += int64(n)
+	return n, err
+}
+
+func (r *rotater) Position() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.written
+}
+
+func (r *rotater) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inner.Close()
+}
+
+func (r *rotater) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inner.Close()
+	f, err := os.OpenFile(r.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	r.inner, r.when = f, time.Now()
+	if i, err := f.Stat(); err == nil {
+		r.written = i.Size()
+	}
+	return nil
+}
+
+func (r *rotater) rotate() error {
+	if err := r.inner.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.file, r.file+"."+r.when.Format("20060102-150405.000")); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotater) open() error {
+	f, err := os.Create(r.file)
+	if err != nil {
+		return err
+	}
+	r.inner, r.written, r.when = f, 0, time.Now()
+	return nil
+}
+
+type window struct {
+	size int
+	seen map[uint32]struct{}
+	keys []uint32
+}
+
+func (w *window) Seen(seq uint32) bool {
+	if _, ok := w.seen[seq]; ok {
+		return true
+	}
+	w.seen[seq] = struct{}{}
+	w.keys = append(w.keys, seq)
+	if len(w.keys) > w.size {
+		delete(w.seen, w.keys[0])
+		w.keys = w.keys[1:]
+	}
+	return false
+}
+
+func markDuplicates(ctx context.Context, queue <-chan *TimeCadu, size int, list bool) <-chan *TimeCadu {
+	const line = "[duplicate] %-10s | %s | %8d"
+
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+
+		ws := make(map[cadus.ChannelKey]*window)
+		for c := range queue {
+			k := c.Key()
+			w, ok := ws[k]
+			if !ok {
+				w = &window{size: size, seen: make(map[uint32]struct{})}
+				ws[k] = w
+			}
+			if c.Duplicate = w.Seen(c.Sequence); c.Duplicate && list {
+				rows.Printf(line, k, formatTime(c.Reception), c.Sequence)
+			}
+			if !send(ctx, q, c) {
+				return
+			}
+		}
+	}()
+	return q
+}
+
+func markJumps(ctx context.Context, queue <-chan *TimeCadu) <-chan *TimeCadu {
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+
+		prevs := make(map[cadus.ChannelKey]*TimeCadu)
+		for c := range queue {
+			k := c.Key()
+			if p := prevs[k]; p != nil && !c.Duplicate && !c.Resumed {
+				c.Backward = c.Cadu.Backward(p.Cadu)
+			}
+			if !c.Duplicate {
+				prevs[k] = c
+			}
+			if !send(ctx, q, c) {
+				return
+			}
+		}
+	}()
+	return q
+}
+
+func writeRaw(ctx context.Context, queue <-chan *TimeCadu, w io.Writer) <-chan *TimeCadu {
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+		for c := range queue {
+			if !c.Duplicate {
+				if _, err := w.Write(c.Bytes()); err != nil {
+					log.Println(err)
+					return
+				}
+			}
+			if !send(ctx, q, c) {
+				return
+			}
+		}
+	}()
+	return q
+}
+
+func summarize(ctx context.Context, queue <-chan *TimeCadu, every time.Duration) <-chan *TimeCadu {
+	const line = "[interim] %s | %8d cadus (%8.1f/s, %8.1fKbps) | %6d missing | %6d corrupted | %s"
+
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+
+		tick := time.NewTicker(every)
+		defer tick.Stop()
+
+		var (
+			prevs = make(map[cadus.ChannelKey]*TimeCadu)
+			sum   Summary
+			last  = time.Now()
+		)
+		for {
+			select {
+			case c, ok := <-queue:
+				if !ok {
+					return
+				}
+				k := c.Key()
+				sum.Update(c, c.Missing(prevs[k]))
+				if !c.Duplicate {
+					prevs[k] = c
+				}
+				if !send(ctx, q, c) {
+					return
+				}
+			case n := <-tick.C:
+				z, secs := sum.Total(), n.Sub(last).Seconds()
+				rate, bits := float64(z.Count)/secs, float64(z.Count*Profile.Length*8)/secs/1000
+				log.Printf(line, formatTime(n), z.Count, rate, bits, z.Missing, z.Corrupted, sum)
+
+				sum, last = Summary{}, n
+			}
+		}
+	}()
+	return q
+}
+
+type gapStats struct {
+	count int
+	gaps  uint32
+	jumps int
+	total time.Duration
+}
+
+type missingRange struct {
+	Space   uint16    `json:"scid"`
+	Channel uint8     `json:"vcid"`
+	Replay  bool      `json:"replay"`
+	First   uint32    `json:"first"`
+	Last    uint32    `json:"last"`
+	Count   uint32    `json:"count"`
+	After   time.Time `json:"after"`
+	Before  time.Time `json:"before"`
+}
+
+func newMissingRange(prev, curr *TimeCadu, count uint32) missingRange {
+	mask := curr.Profile().CounterMask()
+	first, last := prev, curr
+	if (curr.Sequence-prev.Sequence)&mask > mask/2 {
+		first, last = curr, prev
+	}
+	k := curr.Key()
+	return missingRange{
+		Space:   k.Space,
+		Channel: k.Channel,
+		Replay:  k.Replay,
+		First:   (first.Sequence + 1) & mask,
+		Last:    (last.Sequence - 1) & mask,
+		Count:   count,
+		After:   prev.Reception,
+		Before:  curr.Reception,
+	}
+}
+
+func boundary(t time.Time, mark string) time.Time {
+	t = t.In(Location)
+	switch mark {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, Location)
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Location)
+	default:
+		return time.Time{}
+	}
+}
+
+func printGaps(queue <-chan *TimeCadu, mark, manifest string, replay *replayOptions) Summary {
+	const (
+		line = "%-10s | %s | %s | %8d | %8d | %4d | %s"
+		jump = "%-10s | %s | %s | %8d | %8d | backward jump of %d"
+	)
+
+	var (
+		prevs = make(map[cadus.ChannelKey]*TimeCadu)
+		stats = make(map[cadus.ChannelKey]*gapStats)
+		days  = make(map[time.Time]*gapStats)
+		miss  *jsonArray
+		req   *replayWriter
+		z     gapStats
+		sum   Summary
+		last  time.Time
+	)
+	if manifest != "" {
+		a, err := createJSONArray(manifest)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		miss = a
+	}
+	if replay.File != "" {
+		w, err := replay.Create()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		req = w
+	}
+	now := time.Now()
+	for c := range queue {
+		if b := boundary(c.Reception, mark); !b.Equal(last) {
+			if !last.IsZero() {
+				rows.Printf("---------- %s ----------", formatTime(b))
+			}
+			last = b
+		}
+		day := boundary(c.Reception, "day")
+		d, ok := days[day]
+		if !ok {
+			d = &gapStats{}
+			days[day] = d
+		}
+		k := c.Key()
+		prev := prevs[k]
+		s, ok := stats[k]
+		if !ok {
+			s = &gapStats{}
+			stats[k] = s
+		}
+		delta, elapsed := c.Missing(prev), c.Elapsed(prev)
+		s.count++
+		z.count++
+		d.count++
+		sum.Update(c, delta)
+		if delta != 0 {
+			s.gaps += delta
+			s.total += elapsed
+			d.gaps += delta
+			d.total += elapsed
+			z.gaps += delta
+			z.total += elapsed
+			rows.Printf(line, k, formatTime(prev.Reception), formatTime(c.Reception), prev.Sequence, c.Sequence, delta, elapsed)
+			if miss != nil {
+				if err := miss.Write(newMissingRange(prev, c, delta)); err != nil {
+					log.Fatalln(err)
+				}
+			}
+			if req != nil {
+				if err := req.Write(newMissingRange(prev, c, delta)); err != nil {
+					log.Fatalln(err)
+				}
+			}
+		}
+		if c.Backward > 0 {
+			s.jumps++
+			z.jumps++
+			rows.Printf(jump, k, formatTime(prev.Reception), formatTime(c.Reception), prev.Sequence, c.Sequence, c.Backward)
+		}
+		if !c.Duplicate {
+			prevs[k] = c
+		}
+	}
+	log.Println()
+	keys := make([]cadus.ChannelKey, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	cadus.SortKeys(keys)
+	for _, k := range keys {
+		s := stats[k]
+		log.Printf("%-10s: %d/%d missing cadus (%s), %d backward jumps", k, s.gaps, s.count, s.total, s.jumps)
+	}
+	if mark != "" {
+		ds := make([]time.Time, 0, len(days))
+		for d := range days {
+			ds = append(ds, d)
+		}
+		sort.Slice(ds, func(i, j int) bool { return ds[i].Before(ds[j]) })
+		for _, d := range ds {
+			s := days[d]
+			log.Printf("%-10s: %d/%d missing cadus (%s)", d.Format("2006-01-02"), s.gaps, s.count, s.total)
+		}
+	}
+	if miss != nil {
+		if err := miss.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+	if req != nil {
+		if err := req.Close(); err != nil {
+			log.Println(err)
+		}
+		log.Printf("%d replay requests written to %s", req.count, replay.File)
+	}
+	log.Printf("%d/%d missing cadus (%s/%s), %d backward jumps", z.gaps, z.count, z.total, time.Since(now), z.jumps)
+	log.Println(sum)
+	return sum
+}
+
+func printSummary(queue <-chan *TimeCadu, export string) Summary {
+	var (
+		prevs       = make(map[cadus.ChannelKey]*TimeCadu)
+		stats       = make(map[cadus.ChannelKey]*Stats)
+		senders     = make(map[string]*Stats)
+		first, last time.Time
+		sum         Summary
+		arrival     time.Time
+		sizes       sketch
+		durations   sketch
+		intervals   sketch
+	)
+	for c := range queue {
+		k := c.Key()
+		delta := c.Missing(prevs[k])
+		sum.Update(c, delta)
+		if delta > 0 {
+			sizes.Add(float64(delta))
+			durations.Add(c.Elapsed(prevs[k]).Seconds())
+		}
+		if !arrival.IsZero() && c.Reception.After(arrival) {
+			intervals.Add(c.Reception.Sub(arrival).Seconds())
+		}
+		arrival = c.Reception
+
+		s, ok := stats[k]
+		if !ok {
+			s = &Stats{}
+			stats[k] = s
+		}
+		s.Update(c, delta)
+		if c.Sender != "" {
+			if senders[c.Sender] == nil {
+				senders[c.Sender] = &Stats{}
+			}
+			senders[c.Sender].Update(c, 0)
+		}
+		if first.IsZero() || c.Reception.Before(first) {
+			first = c.Reception
+		}
+		if c.Reception.After(last) {
+			last = c.Reception
+		}
+		if !c.Duplicate {
+			prevs[k] = c
+		}
+	}
+	z := sum.Total()
+	elapsed, volume := last.Sub(first), z.Count*Profile.Length
+	var bitrate float64
+	if secs := elapsed.Seconds(); secs > 0 {
+		bitrate = float64(volume*8) / secs / 1000
+	}
+
+	log.Printf("start     : %s", formatTime(first))
+	log.Printf("end       : %s", formatTime(last))
+	log.Printf("duration  : %s", elapsed)
+	log.Printf("volume    : %d cadus, %dKB", z.Count, volume>>10)
+	log.Printf("bitrate   : %.1fKbps", bitrate)
+	log.Printf("errors    : %d missing, %d corrupted", z.Missing, z.Corrupted)
+	log.Printf("quality   : %.4f%% fer, %.4f%% loss, %.2f%% complete", z.FER(), z.Loss(), z.Completeness())
+	gapSizes, gapDurations, arrivals := sizes.Distribution(), durations.Distribution(), intervals.Distribution()
+	log.Printf("gap sizes : %s", gapSizes)
+	log.Printf("gap times : %s", gapDurations.Durations())
+	log.Printf("intervals : %s", arrivals.Durations())
+	log.Printf("realtime  : %s", sum.Realtime)
+	log.Printf("playback  : %s", sum.Playback)
+
+	keys := make([]cadus.ChannelKey, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	cadus.SortKeys(keys)
+	for _, k := range keys {
+		s := stats[k]
+		log.Printf("%-10s: %6.2f%% complete (%d received, %d missing, %d corrupted, %.4f%% fer)", k, s.Completeness(), s.Count, s.Missing, s.Corrupted, s.FER())
+	}
+	if export != "" {
+		r := summaryReport{
+			Start:    first,
+			End:      last,
+			Bitrate:  bitrate,
+			Total:    z.Quality(),
+			Realtime: sum.Realtime.Quality(),
+			Playback: sum.Playback.Quality(),
+			Channels: make(map[string]quality),
+
+			GapSizes:     gapSizes,
+			GapDurations: gapDurations,
+			Intervals:    arrivals,
+		}
+		for k, s := range stats {
+			r.Channels[k.String()] = s.Quality()
+		}
+		if err := writeJSON(export, r); err != nil {
+			log.Println(err)
+		}
+	}
+	names := make([]string, 0, len(senders))
+	for n := range senders {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		s := senders[n]
+		log.Printf("%-21s: %d cadus (%d corrupted, %d duplicated)", n, s.Count, s.Corrupted, s.Duplicate)
+	}
+	return sum
+}
+
+var histogram = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+type jitter struct {
+	sketch
+	counts []int
+}
+
+func (j *jitter) Add(d time.Duration) {
+	j.sketch.Add(float64(d))
+	if j.counts == nil {
+		j.counts = make([]int, len(histogram)+1)
+	}
+	j.counts[sort.Search(len(histogram), func(i int) bool { return d < histogram[i] })]++
+}
+
+func printJitter(queue <-chan *TimeCadu) Summary {
+	const line = "%-10s: %8d intervals | min: %12s | mean: %12s | max: %12s | jitter: %12s | p50: %12s | p90: %12s | p99: %12s"
+
+	var (
+		prevs     = make(map[cadus.ChannelKey]*TimeCadu)
+		intervals = make(map[cadus.ChannelKey]*jitter)
+		sum       Summary
+	)
+	for c := range queue {
+		k := c.Key()
+		prev := prevs[k]
+		sum.Update(c, c.Missing(prev))
+		if c.Duplicate {
+			continue
+		}
+		if prev != nil {
+			j, ok := intervals[k]
+			if !ok {
+				j = &jitter{}
+				intervals[k] = j
+			}
+			j.Add(c.Reception.Sub(prev.Reception))
+		}
+		prevs[k] = c
+	}
+
+	keys := make([]cadus.ChannelKey, 0, len(intervals))
+	for k := range intervals {
+		keys = append(keys, k)
+	}
+	cadus.SortKeys(keys)
+	for _, k := range keys {
+		var (
+			j = intervals[k]
+			d = func(f float64) time.Duration { return time.Duration(f) }
+		)
+		log.Printf(line, k, j.count, d(j.min), d(j.Mean()), d(j.max), d(j.Stddev()), d(j.Percentile(50)), d(j.Percentile(90)), d(j.Percentile(99)))
+
+		for i, n := range j.counts {
+			var label string
+			if i < len(histogram) {
+				label = "< " + histogram[i].String()
+			} else {
+				label = ">= " + histogram[i-1].String()
+			}
+			log.Printf("%-10s  %10s: %8d (%6.2f%%)", "", label, n, float64(n)*100/float64(j.count))
+		}
+	}
+	log.Println(sum)
+	return sum
+}
+
+type Row struct {
+	*TimeCadu
+	Count   int
+	Elapsed time.Duration
+	Total   time.Duration
+	Delta   uint32
+}
+
+type Column struct {
+	Pattern string
+	Value   func(Row) interface{}
+}
+
+const DefaultColumns = "count,time,elapsed,total,word,version,scid,vcid,seq,replay,control,data,crc,gap,error"
+
+var Columns = map[string]Column{
+	"count":   {"%8d", func(r Row) interface{} { return r.Count }},
+	"time":    {"%s", func(r Row) interface{} { return formatTime(r.Reception) }},
+	"elapsed": {"%18s", func(r Row) interface{} { return r.Elapsed }},
+	"total":   {"%18s", func(r Row) interface{} { return r.Total }},
+	"word":    {"%04x", func(r Row) interface{} { return r.Header.Word }},
+	"version": {"%-3d", func(r Row) interface{} { return r.Header.Version }},
+	"scid":    {"%-3d", func(r Row) interface{} { return r.Header.Space }},
+	"vcid":    {"%-3d", func(r Row) interface{} { return r.Header.Channel }},
+	"seq":     {"%-12d", func(r Row) interface{} { return r.Header.Sequence }},
+	"mcfc":    {"%-3d", func(r Row) interface{} { return r.Header.Master }},
+	"replay":  {"%6t", func(r Row) interface{} { return r.Header.Replay }},
+	"control": {"%04x", func(r Row) interface{} { return r.Header.Control }},
+	"data":    {"%-7s", func(r Row) interface{} { return r.Header.PointerString() }},
+	"crc":     {"%04x", func(r Row) interface{} { return r.Control }},
+	"ocf":     {"%08x", func(r Row) interface{} { return r.OCF }},
+	"gap":     {"%4d", func(r Row) interface{} { return r.Delta }},
+	"dup":     {"%5t", func(r Row) interface{} { return r.Duplicate }},
+	"source": {"%-24s", func(r Row) interface{} {
+		if r.Source == "" {
+			return "-"
+		}
+		return r.Source
+	}},
+	"sender": {"%-21s", func(r Row) interface{} {
+		if r.Sender == "" {
+			return "-"
+		}
+		return r.Sender
+	}},
+	"error": {"%s", func(r Row) interface{} {
+		if r.Error == nil {
+			return "-"
+		}
+		return r.Error.Error()
+	}},
+}
+
+func parseColumns(str string) ([]Column, error) {
+	var cs []Column
+	for _, n := range strings.Split(str, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		c, ok := Columns[n]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", n)
+		}
+		cs = append(cs, c)
+	}
+	if len(cs) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+	return cs, nil
+}
+
+func printCadus(queue <-chan *TimeCadu, cs []Column) Summary {
+	ps := make([]string, len(cs))
+	for i, c := range cs {
+		ps[i] = c.Pattern
+	}
+	line := strings.Join(ps, " | ")
+
+	var (
+		prev  *TimeCadu
+		prevs = make(map[cadus.ChannelKey]*TimeCadu)
+		count int
+		total time.Duration
+		sum   Summary
+	)
+	vs := make([]interface{}, len(cs))
+	for c := range queue {
+		k := c.Key()
+		delta, elapsed := c.Missing(prevs[k]), c.Elapsed(prev)
+		total += elapsed
+		sum.Update(c, delta)
+		count++
+
+		r := Row{
+			TimeCadu: c,
+			Count:    count,
+			Elapsed:  elapsed,
+			Total:    total,
+			Delta:    delta,
+		}
+		for i, c := range cs {
+			vs[i] = c.Value(r)
+		}
+		rows.Printf(line, vs...)
+		prev = c
+		if !c.Duplicate {
+			prevs[k] = c
+		}
+	}
+	z := sum.Total()
+	log.Printf("%d cadus found (%d missing, %d corrupted - total time %s)", z.Count, z.Missing, z.Corrupted, total)
+	log.Println(sum)
+	return sum
+}
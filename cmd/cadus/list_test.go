@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSketch(t *testing.T) {
+	var (
+		s  sketch
+		rs = rand.New(rand.NewSource(1))
+		vs = make([]float64, 10000)
+	)
+	for i := range vs {
+		vs[i] = rs.ExpFloat64() * 1000
+		s.Add(vs[i])
+	}
+	sort.Float64s(vs)
+
+	var mean, m2 float64
+	for _, v := range vs {
+		mean += v
+	}
+	mean /= float64(len(vs))
+	for _, v := range vs {
+		m2 += (v - mean) * (v - mean)
+	}
+	if got := s.Mean(); math.Abs(got-mean) > 1e-6 {
+		t.Errorf("mean mismatched: want %f, got %f", mean, got)
+	}
+	if want, got := math.Sqrt(m2/float64(len(vs))), s.Stddev(); math.Abs(got-want) > 1e-6 {
+		t.Errorf("stddev mismatched: want %f, got %f", want, got)
+	}
+	for _, p := range []float64{1, 50, 90, 95, 99, 100} {
+		want := vs[int(math.Ceil(p/100*float64(len(vs))))-1]
+		if got := s.Percentile(p); math.Abs(got-want)/want > 0.01 {
+			t.Errorf("p%.0f: want %f (1%%), got %f", p, want, got)
+		}
+	}
+	if d := s.Distribution(); d.Count != len(vs) || d.Max != vs[len(vs)-1] {
+		t.Errorf("distribution mismatched: want %d values up to %f, got %d values up to %f", len(vs), vs[len(vs)-1], d.Count, d.Max)
+	}
+}
+
+func TestSketchZeros(t *testing.T) {
+	var s sketch
+	if got := s.Percentile(50); got != 0 {
+		t.Errorf("percentile of empty sketch: %f", got)
+	}
+	for _, v := range []float64{0, 0, 0, 5, 10} {
+		s.Add(v)
+	}
+	if got := s.Percentile(50); got != 0 {
+		t.Errorf("p50: want 0, got %f", got)
+	}
+	if got := s.Percentile(100); got != 10 {
+		t.Errorf("p100: want 10, got %f", got)
+	}
+}
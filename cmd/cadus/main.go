@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const TimeFormat = "2006-01-02 15:04:05.000"
+
+type Command struct {
+	Name  string
+	Short string
+	Run   func([]string)
+}
+
+var commands = []*Command{
+	{Name: "list", Short: "list, check and summarize cadus", Run: runList},
+	{Name: "cat", Short: "reassemble HRDL packets from cadus and report", Run: runCat},
+	{Name: "build", Short: "reassemble HRDL packets from a cadus stream", Run: runBuild},
+	{Name: "make", Short: "generate cadus and send them to destinations", Run: runMake},
+	{Name: "relay", Short: "forward cadus from a source to destinations", Run: runRelay},
+	{Name: "verify", Short: "verify PRBS payloads of cadus", Run: runVerify},
+	{Name: "cltu", Short: "decode and verify CLTUs", Run: runCLTU},
+	{Name: "fill", Short: "fill realtime gaps with cadus from playback archives", Run: runFill},
+	{Name: "report", Short: "summarize the archives of the previous period at scheduled times", Run: runReport},
+	{Name: "snmp", Short: "serve link counters to snmpd with the pass_persist protocol", Run: runSNMP},
+}
+
+type percent float64
+
+func (p *percent) String() string {
+	return strconv.FormatFloat(float64(*p)*100, 'f', -1, 64) + "%"
+}
+
+func (p *percent) Set(str string) error {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+	if err != nil {
+		return err
+	}
+	if v < 0 || v > 100 {
+		return fmt.Errorf("%s: percentage out of range", str)
+	}
+	*p = percent(v / 100)
+	return nil
+}
+
+func init() {
+	log.SetOutput(os.Stdout)
+	log.SetFlags(0)
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: cadus <command> [flags] [args...]")
+		fmt.Fprintln(os.Stderr)
+		for _, c := range commands {
+			fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.Name, c.Short)
+		}
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.Name == flag.Arg(0) {
+			c.Run(flag.Args()[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+	flag.Usage()
+	os.Exit(2)
+}
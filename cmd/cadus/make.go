@@ -0,0 +1,844 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+const (
+	DefaultSpacecraft = 23
+	DefaultChannel    = 7
+	DefaultVersion    = 1
+	DefaultSyncword   = 0x1acffc1d
+	DefaultLength     = 1008
+	DefaultReplay     = 0
+	DefaultPointer    = 0x3fff
+	IdleChannel       = 0x3f
+)
+
+// The payload generator of Build is seeded with -seed and the reorder and gap
+// generators with -seed mixed with their own constant, so that none of them
+// draws the sequence of another.
+const (
+	reorderSeed = 0x5DEECE66D
+	gapSeed     = 0x2545F4914F6CDD1D
+)
+
+// Spacecraft is the spacecraft id of the cadus generated, set by the
+// spacecraft key of the missions.
+var Spacecraft = DefaultSpacecraft
+
+type badconn struct {
+	net.Conn
+	rng       *rand.Rand
+	threshold int
+	limit     uint32
+	curr      uint32
+	with      bool
+
+	writer io.Writer
+}
+
+func WithGap(c net.Conn, t int, rng *rand.Rand) net.Conn {
+	return &badconn{
+		Conn:      c,
+		rng:       rng,
+		writer:    c,
+		threshold: t,
+		limit:     uint32(rng.Intn(t)),
+	}
+}
+
+func (b *badconn) Write(bs []byte) (int, error) {
+	b.curr++
+	if b.curr >= b.limit {
+		b.limit, b.curr = uint32(b.rng.Intn(b.threshold)), 0
+		b.with = !b.with
+	}
+	if b.with {
+		b.writer = ioutil.Discard
+	} else {
+		b.writer = b.Conn
+	}
+	return b.writer.Write(bs)
+}
+
+type held struct {
+	frame []byte
+	after int
+}
+
+type reorderconn struct {
+	net.Conn
+	rng   *rand.Rand
+	depth int
+	ratio float64
+	held  []held
+}
+
+func WithReorder(c net.Conn, depth int, ratio float64, rng *rand.Rand) net.Conn {
+	return &reorderconn{
+		Conn:  c,
+		rng:   rng,
+		depth: depth,
+		ratio: ratio,
+	}
+}
+
+func (r *reorderconn) Write(bs []byte) (int, error) {
+	if r.rng.Float64() < r.ratio {
+		vs := make([]byte, len(bs))
+		copy(vs, bs)
+		r.held = append(r.held, held{frame: vs, after: 1 + r.rng.Intn(r.depth)})
+		return len(bs), nil
+	}
+	n, err := r.Conn.Write(bs)
+	if err != nil {
+		return n, err
+	}
+	hs := r.held[:0]
+	for _, h := range r.held {
+		if h.after--; h.after > 0 {
+			hs = append(hs, h)
+			continue
+		}
+		if _, err := r.Conn.Write(h.frame); err != nil {
+			return n, err
+		}
+	}
+	r.held = hs
+	return n, nil
+}
+
+func (r *reorderconn) Close() error {
+	for _, h := range r.held {
+		r.Conn.Write(h.frame)
+	}
+	r.held = r.held[:0]
+	return r.Conn.Close()
+}
+
+type Pacer struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewPacer(bps float64, burst int) *Pacer {
+	if burst <= 0 {
+		burst = 1
+	}
+	z := float64(burst * Profile.Length * 8)
+	return &Pacer{rate: bps, burst: z, tokens: z, last: time.Now()}
+}
+
+func (p *Pacer) Wait(n int) {
+	bits := float64(n * 8)
+	now := time.Now()
+	p.tokens += now.Sub(p.last).Seconds() * p.rate
+	if p.tokens > p.burst {
+		p.tokens = p.burst
+	}
+	p.last = now
+	if p.tokens < bits {
+		wait := time.Duration((bits - p.tokens) / p.rate * float64(time.Second))
+		time.Sleep(wait)
+		p.tokens, p.last = bits, time.Now()
+	}
+	p.tokens -= bits
+}
+
+type bitrate float64
+
+func (b *bitrate) String() string {
+	return strconv.FormatFloat(float64(*b), 'f', -1, 64) + "bps"
+}
+
+func (b *bitrate) Set(str string) error {
+	var (
+		mul = 1.0
+		low = strings.ToLower(strings.TrimSuffix(strings.ToLower(str), "bps"))
+	)
+	switch {
+	case strings.HasSuffix(low, "k"):
+		mul, low = 1e3, strings.TrimSuffix(low, "k")
+	case strings.HasSuffix(low, "m"):
+		mul, low = 1e6, strings.TrimSuffix(low, "m")
+	case strings.HasSuffix(low, "g"):
+		mul, low = 1e9, strings.TrimSuffix(low, "g")
+	}
+	v, err := strconv.ParseFloat(low, 64)
+	if err != nil {
+		return err
+	}
+	if v <= 0 {
+		return fmt.Errorf("%s: invalid bitrate", str)
+	}
+	*b = bitrate(v * mul)
+	return nil
+}
+
+func runMake(args []string) {
+	set := flag.NewFlagSet("make", flag.ExitOnError)
+	threshold := set.Int("t", 0, "threhold")
+	count := set.Int("c", 0, "count")
+	rate := set.Duration("r", time.Millisecond*500, "rate")
+	file := set.String("f", "", "file")
+	proto := set.String("p", "udp", "protocol")
+	idle := set.Float64("idle", 0, "idle cadus generated per data cadu")
+	var corrupt percent
+	set.Var(&corrupt, "corrupt", "percentage of cadus generated with an invalid checksum")
+	depth := set.Int("reorder", 0, "maximum number of positions a cadu is delayed")
+	reorder := percent(0.1)
+	set.Var(&reorder, "reorder-ratio", "percentage of cadus delayed when reordering")
+	var bps bitrate
+	set.Var(&bps, "rate", "target bitrate (e.g. 2Mbps), replacing the per-cadu sleep")
+	burst := set.Int("burst", 1, "number of cadus sent in a burst at the target bitrate")
+	scenario := set.String("s", "", "scenario file")
+	payload := set.String("payload", "file", "payload source (file, prbs, hrdl)")
+	var mopts multicast
+	set.IntVar(&Spacecraft, "spacecraft", Spacecraft, "spacecraft id of the cadus generated")
+	set.IntVar(&mopts.TTL, "ttl", 0, "multicast ttl")
+	set.StringVar(&mopts.Ifname, "ifname", "", "outgoing interface for multicast destinations")
+	set.StringVar(&mopts.Source, "src", "", "source address for multicast destinations")
+	seed := set.Int64("seed", 0, "seed of the random generators (default: current time)")
+	listen := set.String("l", "", "listen for tcp clients on address")
+	from := set.String("from", "current", "position sent to new tcp clients (current, start)")
+	wtimeout := set.Duration("write-timeout", 5*time.Second, "time given to a tcp client to accept a cadu before being disconnected (from current only, 0: no limit)")
+	cltu := set.Bool("cltu", false, "encode generated frames as CLTUs")
+	dry := set.Bool("n", false, "report what would be sent to each destination without sending")
+	key := set.String("key", "", "file holding the pre-shared aes key (raw or hex) encrypting the cadus sent")
+	profileFlags(set)
+	clientFlags(set)
+	compressFlags(set)
+	args = parseArgs(set, args)
+	if err := Profile.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Printf("seed: %d", *seed)
+
+	aead, err := loadKey(*key)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	wrap := func(c net.Conn) net.Conn {
+		c = sealed(c, aead)
+		if *depth > 0 {
+			c = WithReorder(c, *depth, float64(reorder), rand.New(rand.NewSource(*seed^reorderSeed)))
+		}
+		if *threshold > 0 {
+			c = WithGap(c, *threshold, rand.New(rand.NewSource(*seed^gapSeed)))
+		}
+		return c
+	}
+	var phases []Phase
+	if *scenario != "" {
+		ps, err := LoadScenario(*scenario)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		phases = ps
+	}
+	open := func() (*Builder, io.Closer, error) {
+		var r io.ReadCloser
+		switch *payload {
+		case "file", "":
+			f, err := os.Open(*file)
+			if err != nil {
+				return nil, nil, err
+			}
+			r = f
+		case "prbs":
+			r = ioutil.NopCloser(cadus.NewPRBS())
+		case "hrdl":
+			f, err := os.Open(*file)
+			if err != nil {
+				return nil, nil, err
+			}
+			e := cadus.NewEncapsulator(f)
+			e.Size = Profile.BodyLen()
+			r = struct {
+				*cadus.Encapsulator
+				io.Closer
+			}{e, f}
+		default:
+			return nil, nil, fmt.Errorf("unsupported payload %s", *payload)
+		}
+		b := Build(r, *count, *rate, rand.New(rand.NewSource(*seed)))
+		b.idle, b.corrupt, b.cltu = *idle, float64(corrupt), *cltu
+		if bps > 0 {
+			b.pacer = NewPacer(float64(bps), *burst)
+		}
+		if len(phases) > 0 {
+			b.Play(phases)
+		}
+		return b, r, nil
+	}
+
+	if *listen != "" {
+		var err error
+		switch *from {
+		case "current", "":
+			err = serveCurrent(*listen, open, wrap, *wtimeout)
+		case "start":
+			err = serveStart(*listen, open, wrap)
+		default:
+			err = fmt.Errorf("unsupported position %s", *from)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	var fs fanout
+	for _, a := range args {
+		d, err := parseDestination(a, *proto, mopts)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var c net.Conn
+		if *dry {
+			c = dryRun(d.String())
+		} else if c, err = dial(d.Scheme, d.Addr, d.Multicast); err != nil {
+			log.Fatalln(err)
+		}
+		c = wrap(c)
+		fs.Add(d.String(), c, c, d.Pacer())
+	}
+	b, r, err := open()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer r.Close()
+
+	_, err = io.Copy(&fs, b)
+	fs.Close()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if b.pacer == nil {
+		time.Sleep(*rate)
+	}
+}
+
+type multicast struct {
+	TTL    int
+	Ifname string
+	Source string
+}
+
+func dial(scheme, addr string, m multicast) (net.Conn, error) {
+	switch scheme {
+	case "rt":
+		return rollRT(addr, rtInterval)
+	case "rudp":
+		return dialRUDP(addr)
+	}
+	if _, _, ok := compressedScheme(scheme); ok {
+		return dialCompressed(scheme, addr, m)
+	}
+	if !strings.HasPrefix(scheme, "udp") {
+		return net.Dial(scheme, addr)
+	}
+	a, err := net.ResolveUDPAddr(scheme, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !a.IP.IsMulticast() {
+		return net.Dial(scheme, addr)
+	}
+	var (
+		d      net.Dialer
+		ifaddr net.IP
+		index  int
+		v6     = a.IP.To4() == nil
+	)
+	if m.Source != "" {
+		ip := net.ParseIP(m.Source)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source address %s", m.Source)
+		}
+		d.LocalAddr, ifaddr = &net.UDPAddr{IP: ip}, ip
+	}
+	switch {
+	case m.Ifname != "" && v6:
+		ifi, err := net.InterfaceByName(m.Ifname)
+		if err != nil {
+			return nil, err
+		}
+		index = ifi.Index
+	case m.Ifname != "":
+		ifaddr, err = interfaceAddr(m.Ifname)
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.Control = multicastControl(v6, m.TTL, index, ifaddr)
+	return d.Dial(scheme, addr)
+}
+
+func interfaceAddr(name string) (net.IP, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	as, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range as {
+		if n, ok := a.(*net.IPNet); ok && n.IP.To4() != nil {
+			return n.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no ipv4 address", name)
+}
+
+func interfaceByAddr(ip net.IP) (*net.Interface, error) {
+	is, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range is {
+		as, err := is[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range as {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return &is[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: no interface with this address", ip)
+}
+
+type openFunc func() (*Builder, io.Closer, error)
+
+type wrapFunc func(net.Conn) net.Conn
+
+func serveStart(addr string, open openFunc, wrap wrapFunc) error {
+	s, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	sdNotify("READY=1")
+	for {
+		c, err := s.Accept()
+		if err != nil {
+			return err
+		}
+		if c = trackClient(addr, c); c == nil {
+			continue
+		}
+		go func(c net.Conn) {
+			c = wrap(c)
+			defer c.Close()
+
+			b, r, err := open()
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			defer r.Close()
+			io.Copy(c, b)
+		}(c)
+	}
+}
+
+func serveCurrent(addr string, open openFunc, wrap wrapFunc, timeout time.Duration) error {
+	s, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	sdNotify("READY=1")
+
+	bc := broadcaster{timeout: timeout}
+	go func() {
+		for {
+			c, err := s.Accept()
+			if err != nil {
+				return
+			}
+			if c = trackClient(addr, c); c != nil {
+				bc.Add(wrap(c))
+			}
+		}
+	}()
+	defer bc.Close()
+
+	b, r, err := open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(&bc, b)
+	return err
+}
+
+// broadcaster writes every cadu to all the connected clients. A client not
+// accepting a cadu within timeout is disconnected so that it can not stall the
+// others.
+type broadcaster struct {
+	mu      sync.Mutex
+	conns   []net.Conn
+	timeout time.Duration
+}
+
+func (b *broadcaster) Add(c net.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.conns = append(b.conns, c)
+}
+
+func (b *broadcaster) Write(bs []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cs := b.conns[:0]
+	for _, c := range b.conns {
+		if b.timeout > 0 {
+			c.SetWriteDeadline(time.Now().Add(b.timeout))
+		}
+		if _, err := c.Write(bs); err != nil {
+			log.Printf("[tcp] %s: %s", c.RemoteAddr(), err)
+			c.Close()
+			continue
+		}
+		cs = append(cs, c)
+	}
+	b.conns = cs
+	return len(bs), nil
+}
+
+func (b *broadcaster) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	b.conns = b.conns[:0]
+	return nil
+}
+
+func DebugW(w io.Writer) io.Writer {
+	g, err := ioutil.TempFile("", "camake-w.raw-")
+	if err != nil {
+		return w
+	}
+	return io.MultiWriter(w, g)
+}
+
+func DebugR(r io.Reader) io.Reader {
+	w, err := ioutil.TempFile("", "camake-r.raw-")
+	if err != nil {
+		return r
+	}
+	return io.TeeReader(r, w)
+}
+
+type Builder struct {
+	inner io.Reader
+
+	sleep   time.Duration
+	limit   uint32
+	counter uint32
+
+	channels []uint8
+	counters map[uint8]uint32
+	replay   bool
+
+	idle    float64
+	pending float64
+
+	corrupt float64
+	cltu    bool
+	fill    []byte
+	master  uint8
+
+	gapEvery  int
+	gapLength int
+
+	pacer *Pacer
+	rng   *rand.Rand
+
+	phases []Phase
+	phase  int
+	since  time.Time
+	sent   int
+}
+
+func Build(r io.Reader, c int, s time.Duration, rng *rand.Rand) *Builder {
+	return &Builder{
+		inner:    r,
+		rng:      rng,
+		limit:    uint32(c),
+		sleep:    s,
+		channels: []uint8{DefaultChannel},
+		counters: make(map[uint8]uint32),
+		fill:     bytes.Repeat(cadus.IdlePayload[:1], Profile.BodyLen()),
+	}
+}
+
+func (b *Builder) Play(ps []Phase) {
+	b.phases, b.phase = ps, -1
+}
+
+func (b *Builder) Read(bs []byte) (int, error) {
+	if len(bs) < Profile.Length {
+		return 0, io.ErrShortBuffer
+	}
+	if err := b.advance(); err != nil {
+		return 0, err
+	}
+	if b.pending >= 1 {
+		b.pending--
+		seq := b.counters[IdleChannel]
+		b.counters[IdleChannel]++
+		return b.encode(bs, IdleChannel, seq, cadus.PointerIdle, bytes.NewReader(b.fill))
+	}
+	if b.limit > 0 && b.counter >= b.limit {
+		return 0, io.EOF
+	}
+	payload, pointer := b.inner, uint16(DefaultPointer)
+	if s, ok := b.inner.(cadus.Segmenter); ok {
+		vs, p, err := s.Segment()
+		if err != nil {
+			return 0, err
+		}
+		payload, pointer = bytes.NewReader(vs), (DefaultPointer&^cadus.PointerMask)|p
+	}
+	ch := b.channels[b.sent%len(b.channels)]
+	seq := b.counters[ch]
+	n, err := b.encode(bs, ch, seq, pointer, payload)
+	if err == nil {
+		b.counter++
+		b.sent++
+		b.counters[ch]++
+		if b.gapEvery > 0 && b.sent%b.gapEvery == 0 {
+			b.counters[ch] += uint32(b.gapLength)
+		}
+		b.pending += b.idle
+	}
+	return n, err
+}
+
+func (b *Builder) advance() error {
+	if len(b.phases) == 0 {
+		return nil
+	}
+	if b.phase >= 0 && !b.phases[b.phase].Done(b.since, b.sent) {
+		return nil
+	}
+	if b.phase++; b.phase >= len(b.phases) {
+		return io.EOF
+	}
+	p := b.phases[b.phase]
+	b.channels = p.Channels
+	if len(b.channels) == 0 {
+		b.channels = []uint8{DefaultChannel}
+	}
+	b.replay = p.Replay
+	b.idle, b.pending = p.Idle, 0
+	b.corrupt = p.Corrupt
+	b.gapEvery, b.gapLength = p.GapEvery, p.GapLength
+	b.pacer = nil
+	if p.Rate > 0 {
+		b.pacer = NewPacer(p.Rate, p.Burst)
+	}
+	b.since, b.sent = time.Now(), 0
+	return nil
+}
+
+type Phase struct {
+	Duration  time.Duration
+	Count     int
+	Rate      float64
+	Burst     int
+	Channels  []uint8
+	GapEvery  int
+	GapLength int
+	Corrupt   float64
+	Idle      float64
+	Replay    bool
+}
+
+func (p Phase) Done(since time.Time, sent int) bool {
+	if p.Count > 0 && sent >= p.Count {
+		return true
+	}
+	return p.Duration > 0 && time.Since(since) >= p.Duration
+}
+
+func LoadScenario(file string) ([]Phase, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		ps []Phase
+		s  = bufio.NewScanner(r)
+	)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if ix := strings.Index(line, "#"); ix >= 0 {
+			line = line[:ix]
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if line == "[[phase]]" {
+			ps = append(ps, Phase{})
+			continue
+		}
+		ix := strings.Index(line, "=")
+		if ix < 0 || len(ps) == 0 {
+			return nil, fmt.Errorf("%s:%d: unexpected line %q", file, n, line)
+		}
+		key, value := strings.TrimSpace(line[:ix]), strings.TrimSpace(line[ix+1:])
+		if err := ps[len(ps)-1].set(key, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %v", file, n, key, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for i, p := range ps {
+		if p.Duration <= 0 && p.Count <= 0 {
+			return nil, fmt.Errorf("%s: phase %d: duration or count required", file, i+1)
+		}
+	}
+	return ps, nil
+}
+
+func (p *Phase) set(key, value string) error {
+	var err error
+	switch str := strings.Trim(value, "\""); key {
+	case "duration":
+		p.Duration, err = time.ParseDuration(str)
+	case "count":
+		p.Count, err = strconv.Atoi(str)
+	case "rate":
+		var b bitrate
+		err = b.Set(str)
+		p.Rate = float64(b)
+	case "burst":
+		p.Burst, err = strconv.Atoi(str)
+	case "vcids":
+		if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+			return fmt.Errorf("array expected")
+		}
+		p.Channels = p.Channels[:0]
+		for _, v := range strings.Split(strings.Trim(value, "[]"), ",") {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			c, err := strconv.ParseUint(v, 0, 6)
+			if err != nil {
+				return err
+			}
+			p.Channels = append(p.Channels, uint8(c))
+		}
+	case "gap_every":
+		p.GapEvery, err = strconv.Atoi(str)
+	case "gap_length":
+		p.GapLength, err = strconv.Atoi(str)
+	case "corrupt":
+		var c percent
+		err = c.Set(str)
+		p.Corrupt = float64(c)
+	case "idle":
+		p.Idle, err = strconv.ParseFloat(str, 64)
+	case "replay":
+		p.Replay, err = strconv.ParseBool(str)
+	default:
+		err = fmt.Errorf("unknown key")
+	}
+	return err
+}
+
+func (b *Builder) encode(bs []byte, channel uint8, counter uint32, pointer uint16, r io.Reader) (int, error) {
+	c := cadus.Cadu{
+		Header: &cadus.Header{
+			Word:     DefaultSyncword,
+			Version:  DefaultVersion,
+			Space:    uint16(Spacecraft) & 0xFF,
+			Channel:  channel,
+			Master:   b.master,
+			Sequence: counter,
+			Replay:   b.replay,
+			Data:     pointer,
+		},
+		Payload: make([]byte, Profile.BodyLen()),
+	}
+	if _, err := io.ReadFull(r, c.Payload); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.ErrShortWrite
+		}
+		return 0, err
+	}
+	if Profile.Frame == cadus.FrameTM {
+		c.Space = uint16(Spacecraft) & 0x3FF
+	}
+	if b.replay {
+		c.Signaling |= 1 << 7
+	}
+	if Profile.FHEC {
+		pid := uint16(c.Version)<<14 | c.Space<<6 | uint16(c.Channel)
+		c.Header.Control = cadus.FHEC(pid, c.Signaling)
+	}
+	if Profile.Insert > 0 {
+		c.Insert = make([]byte, Profile.Insert)
+	}
+	// the reed-solomon check symbols are zeroed, decoders skip them
+	c.Check = make([]byte, Profile.RS*cadus.RSBlockLen)
+	if Profile.FECF {
+		plain := Profile
+		plain.Randomized = false
+		vs := plain.Encode(&c)
+		c.Control = cadus.CalculateCRC(vs[len(cadus.CaduMagic) : len(vs)-len(c.Check)-cadus.CaduCRCLen])
+		if b.corrupt > 0 && b.rng.Float64() < b.corrupt {
+			c.Control = ^c.Control
+		}
+	}
+	b.master++
+
+	frame := Profile.Encode(&c)
+	if b.cltu {
+		frame = cadus.EncodeCLTU(frame[len(cadus.CaduMagic):])
+	}
+	if len(frame) > len(bs) {
+		return 0, io.ErrShortBuffer
+	}
+	if b.pacer != nil {
+		b.pacer.Wait(len(frame))
+	} else {
+		time.Sleep(b.sleep)
+	}
+	return copy(bs, frame), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/busoc/cadus"
+)
+
+func TestBuilderProfile(t *testing.T) {
+	defer func(p cadus.Profile) {
+		Profile = p
+	}(Profile)
+
+	data := []struct {
+		Name    string
+		Profile cadus.Profile
+		Corrupt float64
+		Want    interface{}
+	}{
+		{Name: "aos", Profile: cadus.DefaultProfile},
+		{Name: "aos+insert+ocf", Profile: cadus.Profile{Frame: cadus.FrameAOS, Length: 1279, FHEC: true, Check: true, Insert: 6, OCF: true, FECF: true}},
+		{Name: "aos+randomized+rs", Profile: cadus.Profile{Frame: cadus.FrameAOS, Length: 1279, FHEC: true, FECF: true, Randomized: true, RS: 4}},
+		{Name: "tm", Profile: cadus.Profile{Frame: cadus.FrameTM, Length: 1115, FECF: true}},
+		{Name: "corrupt", Profile: cadus.DefaultProfile, Corrupt: 1, Want: cadus.ChecksumError{}},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			Profile = d.Profile
+
+			b := Build(cadus.NewPRBS(), 3, 0, rand.New(rand.NewSource(1)))
+			b.corrupt = d.Corrupt
+			bs := make([]byte, 2*Profile.Length)
+			for i := 0; i < 3; i++ {
+				n, err := b.Read(bs)
+				if err != nil {
+					t.Fatalf("unexpected error building cadu: %s", err)
+				}
+				if n != Profile.Length {
+					t.Fatalf("want %d bytes, got %d", Profile.Length, n)
+				}
+				c, err := Profile.Decode(bytes.NewReader(bs[:n]))
+				if err != nil {
+					t.Fatalf("unexpected error decoding cadu: %s", err)
+				}
+				if reflect.TypeOf(c.Error) != reflect.TypeOf(d.Want) {
+					t.Errorf("unexpected error: want %T, got %v", d.Want, c.Error)
+				}
+				if c.Space != DefaultSpacecraft || c.Channel != DefaultChannel || c.Sequence != uint32(i) {
+					t.Errorf("unexpected header: %+v", *c.Header)
+				}
+				c.Release()
+			}
+		})
+	}
+}
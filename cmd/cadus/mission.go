@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mission gives the defaults of a mission: the frame options shared by all
+// the commands decoding cadus, the options specific to one command and the
+// virtual channels the spacecraft is expected to downlink.
+type mission struct {
+	Options  map[string]string
+	Commands map[string]map[string]string
+	Channels []uint8
+}
+
+var missions = map[string]mission{
+	"hrdl": {
+		Options: map[string]string{
+			"frame":      "aos",
+			"cadu-len":   "1024",
+			"fhec":       "true",
+			"fecf":       "true",
+			"randomized": "false",
+			"rs":         "0",
+		},
+		Commands: map[string]map[string]string{
+			"build": {"u": "2"},
+			"make":  {"spacecraft": "23"},
+			"cat": {
+				"channel-at":     "8",
+				"channel-seq-at": "12",
+				"origin-at":      "9",
+				"source-at":      "47",
+				"origin-seq-at":  "27",
+			},
+		},
+		Channels: []uint8{7, 63},
+	},
+	"aos-rs5": {
+		Options: map[string]string{
+			"frame":      "aos",
+			"cadu-len":   "1279",
+			"fhec":       "true",
+			"fecf":       "true",
+			"randomized": "true",
+			"rs":         "5",
+		},
+	},
+	"tm-rs5": {
+		Options: map[string]string{
+			"frame":      "tm",
+			"cadu-len":   "1279",
+			"fecf":       "true",
+			"randomized": "true",
+			"rs":         "5",
+		},
+	},
+}
+
+func applyMission(set *flag.FlagSet, name string) error {
+	m, ok := missions[name]
+	if !ok {
+		var ns []string
+		for n := range missions {
+			ns = append(ns, n)
+		}
+		sort.Strings(ns)
+		return fmt.Errorf("%s: unknown mission (%s)", name, strings.Join(ns, ", "))
+	}
+	opts := make(map[string]string)
+	if set.Lookup("frame") != nil {
+		for k, v := range m.Options {
+			opts[k] = v
+		}
+	}
+	for k, v := range m.Commands[set.Name()] {
+		opts[k] = v
+	}
+	if len(m.Channels) > 0 && set.Lookup("expect-vcid") != nil {
+		vs := make([]string, len(m.Channels))
+		for i, c := range m.Channels {
+			vs[i] = strconv.Itoa(int(c))
+		}
+		opts["expect-vcid"] = strings.Join(vs, ",")
+	}
+
+	seen := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) {
+		seen[f.Name] = true
+	})
+	for k, v := range opts {
+		if set.Lookup(k) == nil {
+			return fmt.Errorf("%s.%s: unknown option for %s", name, k, set.Name())
+		}
+		if seen[k] {
+			continue
+		}
+		if err := set.Set(k, v); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, k, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func TestApplyMission(t *testing.T) {
+	t.Run("expected", func(t *testing.T) {
+		set := flag.NewFlagSet("list", flag.ContinueOnError)
+		var frame, size, fhec, fecf, rand, rs string
+		for _, f := range []struct {
+			Name string
+			Ptr  *string
+		}{{"frame", &frame}, {"cadu-len", &size}, {"fhec", &fhec}, {"fecf", &fecf}, {"randomized", &rand}, {"rs", &rs}} {
+			set.StringVar(f.Ptr, f.Name, "", "")
+		}
+		f := filterFlags(set)
+		set.Parse([]string{"-rs", "8"})
+
+		if err := applyMission(set, "hrdl"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if size != "1024" || frame != "aos" {
+			t.Errorf("frame options not set: want aos/1024, got %s/%s", frame, size)
+		}
+		if rs != "8" {
+			t.Errorf("option given on the command line overwritten: got rs %s", rs)
+		}
+		if !f.Expected.Has(7) || !f.Expected.Has(63) || f.Expected.Has(5) {
+			t.Errorf("unexpected virtual channels: got %s", f.Expected.String())
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		set := flag.NewFlagSet("build", flag.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		set.String("frame", "", "")
+		if err := applyMission(set, "hrdl"); err == nil {
+			t.Fatalf("mission applied to a command without its options")
+		}
+	})
+	t.Run("mission", func(t *testing.T) {
+		if err := applyMission(flag.NewFlagSet("list", flag.ContinueOnError), "unknown"); err == nil {
+			t.Fatalf("unknown mission applied")
+		}
+	})
+}
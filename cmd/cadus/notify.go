@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+func sdNotify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = c.Write([]byte(state))
+	return err
+}
+
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+func watchdog(ctx context.Context, queue <-chan *TimeCadu) <-chan *TimeCadu {
+	every := watchdogInterval()
+	if every <= 0 {
+		return queue
+	}
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+
+		tick := time.NewTicker(every)
+		defer tick.Stop()
+		for {
+			select {
+			case c, ok := <-queue:
+				if !ok || !send(ctx, q, c) {
+					return
+				}
+			case <-tick.C:
+				sdNotify("WATCHDOG=1")
+			}
+		}
+	}()
+	return q
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/busoc/cadus"
+)
+
+type apidKey struct {
+	cadus.ChannelKey
+	Encap bool
+	APID  uint16
+}
+
+func (k apidKey) String() string {
+	if k.Encap {
+		return fmt.Sprintf("%-10s encap %4s", k.ChannelKey, cadus.ProtocolName(uint8(k.APID)))
+	}
+	return fmt.Sprintf("%-10s apid  %4d", k.ChannelKey, k.APID)
+}
+
+type apidStats struct {
+	Count   int
+	Missing int
+	Size    int
+	last    *cadus.Packet
+}
+
+func (s apidStats) Loss() float64 {
+	if total := s.Count + s.Missing; total > 0 {
+		return float64(s.Missing) / float64(total) * 100
+	}
+	return 0
+}
+
+func (s apidStats) Completeness() float64 {
+	if total := s.Count + s.Missing; total > 0 {
+		return float64(s.Count) / float64(total) * 100
+	}
+	return 0
+}
+
+type zoneStats struct {
+	Zone  int
+	Data  int
+	Fill  int
+	Idles int
+}
+
+func (s zoneStats) Ratio(n int) float64 {
+	if s.Zone == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(s.Zone)
+}
+
+type packetFilter struct {
+	APID       int
+	Service    int
+	Subservice int
+	PUS        bool
+	CUC        string
+
+	coarse int
+	fine   int
+}
+
+func packetFlags(set *flag.FlagSet) *packetFilter {
+	f := packetFilter{CUC: "4.2"}
+	set.IntVar(&f.APID, "apid", -1, "keep only packets of apid in apid mode")
+	set.BoolVar(&f.PUS, "pus", false, "decode pus tm secondary headers in apid mode")
+	set.StringVar(&f.CUC, "pus-time", f.CUC, "coarse.fine octets of the pus on-board time")
+	set.IntVar(&f.Service, "service", -1, "keep only pus packets of service type")
+	set.IntVar(&f.Subservice, "subservice", -1, "keep only pus packets of service subtype")
+	return &f
+}
+
+func (f *packetFilter) parse() error {
+	if _, err := fmt.Sscanf(f.CUC, "%d.%d", &f.coarse, &f.fine); err != nil {
+		return fmt.Errorf("%s: invalid pus time format", f.CUC)
+	}
+	if !f.PUS && (f.Service >= 0 || f.Subservice >= 0) {
+		f.PUS = true
+	}
+	return nil
+}
+
+func (f *packetFilter) Keep(h *cadus.PUS) bool {
+	if f.Service < 0 && f.Subservice < 0 {
+		return true
+	}
+	if h == nil {
+		return false
+	}
+	if f.Service >= 0 && int(h.Service) != f.Service {
+		return false
+	}
+	return f.Subservice < 0 || int(h.Subservice) == f.Subservice
+}
+
+func printPackets(queue <-chan *TimeCadu, f *packetFilter) Summary {
+	const (
+		line  = "%s | %s | %5d | %6d bytes | %4d"
+		ptype = " | %3d/%-3d | %s"
+	)
+	if err := f.parse(); err != nil {
+		log.Fatalln(err)
+	}
+
+	var (
+		prevs = make(map[cadus.ChannelKey]*TimeCadu)
+		stats = make(map[apidKey]*apidStats)
+		zones = make(map[cadus.ChannelKey]*zoneStats)
+		ex    = cadus.NewExtractor()
+		idle  int
+		sum   Summary
+	)
+	if Profile.Tolerant {
+		ex.Limit = MaxBuffer
+	}
+	for c := range queue {
+		k := c.Key()
+		sum.Update(c, c.Missing(prevs[k]))
+		if c.Duplicate {
+			continue
+		}
+		prevs[k] = c
+
+		z, ok := zones[k]
+		if !ok {
+			z = &zoneStats{}
+			zones[k] = z
+		}
+		z.Zone += len(c.Payload)
+		if c.Error == nil && c.Pointer() == cadus.PointerIdle {
+			z.Fill += len(c.Payload)
+			z.Idles++
+		}
+		for _, p := range ex.Extract(c.Cadu) {
+			if p.Idle() {
+				idle++
+				z.Fill += p.Len()
+				continue
+			}
+			z.Data += p.Len()
+			if f.APID >= 0 && (p.Encapsulated() || int(p.APID) != f.APID) {
+				continue
+			}
+			a := apidKey{ChannelKey: k, APID: p.APID}
+			if p.Encapsulated() {
+				a.Encap, a.APID = true, uint16(p.Protocol)
+			}
+			s, ok := stats[a]
+			if !ok {
+				s = &apidStats{}
+				stats[a] = s
+			}
+			delta := p.Missing(s.last)
+			s.Count++
+			s.Missing += int(delta)
+			s.Size += p.Len()
+			s.last = p
+
+			var h *cadus.PUS
+			if f.PUS {
+				h, _ = cadus.DecodePUS(p, f.coarse, f.fine)
+			}
+			if !f.Keep(h) {
+				continue
+			}
+			row := fmt.Sprintf(line, a, formatTime(c.Reception), p.Sequence, p.Len(), delta)
+			switch {
+			case h != nil:
+				row += fmt.Sprintf(ptype, h.Service, h.Subservice, formatTime(onboardTime(h.Coarse, h.Fine)))
+			case f.PUS:
+				row += " | -"
+			}
+			rows.Println(row)
+		}
+	}
+	keys := make([]apidKey, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ChannelKey != keys[j].ChannelKey {
+			ks := []cadus.ChannelKey{keys[i].ChannelKey, keys[j].ChannelKey}
+			cadus.SortKeys(ks)
+			return ks[0] == keys[i].ChannelKey
+		}
+		if keys[i].Encap != keys[j].Encap {
+			return !keys[i].Encap
+		}
+		return keys[i].APID < keys[j].APID
+	})
+	for _, k := range keys {
+		s := stats[k]
+		log.Printf("%s: %6.2f%% complete (%d packets, %d missing, %.4f%% loss, %dKB)", k, s.Completeness(), s.Count, s.Missing, s.Loss(), s.Size>>10)
+	}
+	ks := make([]cadus.ChannelKey, 0, len(zones))
+	for k := range zones {
+		ks = append(ks, k)
+	}
+	cadus.SortKeys(ks)
+	for _, k := range ks {
+		z := zones[k]
+		log.Printf("%-10s: %6.2f%% data, %6.2f%% fill (%dKB data, %dKB fill, %d idle cadus)", k, z.Ratio(z.Data), z.Ratio(z.Fill), z.Data>>10, z.Fill>>10, z.Idles)
+	}
+	log.Printf("%d idle packets, %d partial packets dropped", idle, ex.Dropped)
+	log.Println(sum)
+	return sum
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+type pass struct {
+	Stats
+	Start time.Time
+	End   time.Time
+}
+
+func (p pass) Volume() int {
+	return p.Count * Profile.Length
+}
+
+func printPasses(queue <-chan *TimeCadu, silence time.Duration) Summary {
+	const line = "pass %3d: %s - %s | %12s | %8d cadus | %8dKB | %6.2f%% complete (%d missing, %d corrupted)"
+
+	var (
+		prevs  = make(map[cadus.ChannelKey]*TimeCadu)
+		passes []*pass
+		curr   *pass
+		sum    Summary
+	)
+	for c := range queue {
+		if curr == nil || c.Reception.Sub(curr.End) > silence {
+			curr = &pass{Start: c.Reception}
+			passes = append(passes, curr)
+			prevs = make(map[cadus.ChannelKey]*TimeCadu)
+		}
+		k := c.Key()
+		delta := c.Missing(prevs[k])
+		sum.Update(c, delta)
+		curr.Update(c, delta)
+		if c.Reception.After(curr.End) {
+			curr.End = c.Reception
+		}
+		if !c.Duplicate {
+			prevs[k] = c
+		}
+	}
+	for i, p := range passes {
+		rows.Printf(line, i+1, formatTime(p.Start), formatTime(p.End), p.End.Sub(p.Start), p.Count, p.Volume()>>10, p.Completeness(), p.Missing, p.Corrupted)
+	}
+	return sum
+}
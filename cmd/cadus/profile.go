@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+
+	"github.com/busoc/cadus"
+)
+
+var Profile = cadus.DefaultProfile
+
+func profileFlags(set *flag.FlagSet) {
+	set.Var(frameFlag{&Profile}, "frame", "transfer frame type (aos, tm: without fhec)")
+	set.IntVar(&Profile.Length, "cadu-len", Profile.Length, "length of cadus including syncword and crc")
+	set.BoolVar(&Profile.FHEC, "fhec", Profile.FHEC, "cadus have a frame header error control field")
+	set.BoolVar(&Profile.Check, "check-fhec", Profile.Check, "validate the frame header error control field")
+	set.IntVar(&Profile.Insert, "insert", Profile.Insert, "length of the insert zone")
+	set.BoolVar(&Profile.OCF, "ocf", Profile.OCF, "cadus have an operational control field (aos only, tm uses the header flag)")
+	set.BoolVar(&Profile.FECF, "fecf", Profile.FECF, "cadus have a frame error control field")
+	set.BoolVar(&Profile.Randomized, "randomized", Profile.Randomized, "cadus are randomized with the ccsds pseudo-random sequence")
+	set.IntVar(&Profile.RS, "rs", Profile.RS, "interleaving depth of reed-solomon check symbols skipped after each cadu (not corrected)")
+	set.IntVar(&Profile.Head, "skip-head", Profile.Head, "bytes skipped before each cadu")
+	set.IntVar(&Profile.Tail, "skip-tail", Profile.Tail, "bytes skipped after each cadu")
+	set.BoolVar(&Profile.Tolerant, "tolerant", Profile.Tolerant, "report malformed cadus as decoding errors instead of crashing on them")
+}
+
+type frameFlag struct {
+	*cadus.Profile
+}
+
+func (f frameFlag) String() string {
+	if f.Profile == nil {
+		return ""
+	}
+	return f.Frame
+}
+
+func (f frameFlag) Set(str string) error {
+	cadus.WithFrame(str)(f.Profile)
+	return nil
+}
+
+func newReader(r io.Reader) *cadus.Reader {
+	rs, err := cadus.NewReader(r, cadus.WithProfile(Profile))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return rs
+}
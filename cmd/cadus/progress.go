@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var Progress bool
+
+const progressEvery = 5 * time.Second
+
+type progress struct {
+	total int64
+	read  int64
+	start time.Time
+}
+
+func newProgress(ctx context.Context, paths []string, skip int64) *progress {
+	if !Progress {
+		return nil
+	}
+	p := progress{total: -skip, start: time.Now()}
+	for _, f := range paths {
+		if i, err := os.Stat(f); err == nil {
+			p.total += i.Size()
+		}
+	}
+	go p.report(ctx)
+	return &p
+}
+
+func (p *progress) Reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &countReader{Reader: r, count: &p.read}
+}
+
+func (p *progress) Done() {
+	if p != nil {
+		p.print()
+		atomic.StoreInt64(&p.total, 0)
+	}
+}
+
+func (p *progress) report(ctx context.Context) {
+	tick := time.NewTicker(progressEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if atomic.LoadInt64(&p.total) <= 0 {
+				return
+			}
+			p.print()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *progress) print() {
+	read, total := atomic.LoadInt64(&p.read), atomic.LoadInt64(&p.total)
+	if total <= 0 {
+		return
+	}
+	var (
+		elapsed = time.Since(p.start)
+		ratio   = float64(read) / float64(total)
+		eta     time.Duration
+	)
+	if read > 0 && read < total {
+		eta = time.Duration(float64(elapsed) * float64(total-read) / float64(read))
+	}
+	log.Printf("[progress] %6.2f%% (%dKB/%dKB) | elapsed %s | eta %s", ratio*100, read>>10, total>>10, elapsed.Round(time.Second), eta.Round(time.Second))
+}
+
+type countReader struct {
+	io.Reader
+	count *int64
+}
+
+func (c *countReader) Read(bs []byte) (int, error) {
+	n, err := c.Reader.Read(bs)
+	atomic.AddInt64(c.count, int64(n))
+	return n, err
+}
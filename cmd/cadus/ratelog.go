@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLogLimit = 10
+	DefaultLogEvery = 10 * time.Second
+)
+
+type rateLogger struct {
+	mu     sync.Mutex
+	logger *log.Logger
+	limit  int
+	every  time.Duration
+	start  time.Time
+	counts map[string]int
+}
+
+func newRateLogger(logger *log.Logger, limit int, every time.Duration) *rateLogger {
+	return &rateLogger{
+		logger: logger,
+		limit:  limit,
+		every:  every,
+		start:  time.Now(),
+		counts: make(map[string]int),
+	}
+}
+
+func (r *rateLogger) Println(err error) {
+	r.Printf(errorKind(err), "%s", err)
+}
+
+func (r *rateLogger) Printf(key, format string, args ...interface{}) {
+	if r.limit <= 0 {
+		r.logger.Printf(format, args...)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.every > 0 && time.Since(r.start) >= r.every {
+		r.flush()
+	}
+	if r.counts[key]++; r.counts[key] <= r.limit {
+		r.logger.Printf(format, args...)
+	}
+}
+
+func (r *rateLogger) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flush()
+}
+
+func (r *rateLogger) flush() {
+	keys := make([]string, 0, len(r.counts))
+	for k, c := range r.counts {
+		if c > r.limit {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	elapsed := time.Since(r.start).Round(time.Second)
+	for _, k := range keys {
+		c := r.counts[k]
+		r.logger.Printf("%s ×%d in last %s (%d not shown)", k, c, elapsed, c-r.limit)
+	}
+	r.counts, r.start = make(map[string]int), time.Now()
+}
+
+func errorKind(err error) string {
+	str := err.Error()
+	if ix := strings.Index(str, ":"); ix >= 0 {
+		str = str[:ix]
+	}
+	return str
+}
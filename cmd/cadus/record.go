@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var RecordFile string
+
+func recordFlags(set *flag.FlagSet) {
+	set.StringVar(&RecordFile, "record", RecordFile, "write the raw datagrams and tcp streams received by live sources to a pcap file (read back with -p pcap+udp or pcap+tcp)")
+}
+
+const (
+	recordSnapLen = 65535
+	linkEthernet  = 1
+	protoTCP      = 6
+	protoUDP      = 17
+)
+
+// Recorder is nil unless -record is given: its methods do nothing then.
+var Recorder *recorder
+
+type recorder struct {
+	mu    sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	ident uint16
+	err   error
+}
+
+func createRecorder(ctx context.Context, file string) (*recorder, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	r := recorder{file: f, w: bufio.NewWriter(f)}
+
+	head := make([]byte, pcapHeaderLen)
+	binary.LittleEndian.PutUint32(head, pcapMagicNano)
+	binary.LittleEndian.PutUint16(head[4:], 2)
+	binary.LittleEndian.PutUint16(head[6:], 4)
+	binary.LittleEndian.PutUint32(head[16:], recordSnapLen)
+	binary.LittleEndian.PutUint32(head[20:], linkEthernet)
+	if _, err := r.w.Write(head); err != nil {
+		f.Close()
+		return nil, err
+	}
+	go func() {
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				r.mu.Lock()
+				if r.w != nil {
+					r.flush()
+				}
+				r.mu.Unlock()
+			case <-ctx.Done():
+				r.Close()
+				return
+			}
+		}
+	}()
+	return &r, nil
+}
+
+func (r *recorder) flush() {
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+		log.Printf("[record] %s: %s", r.file.Name(), err)
+	}
+}
+
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return nil
+	}
+	r.flush()
+	r.w = nil
+	return r.file.Close()
+}
+
+// Datagram records an udp datagram with the ethernet, ip and udp headers that
+// decodeFromPCAP skips.
+func (r *recorder) Datagram(when time.Time, from, to *net.UDPAddr, payload []byte) {
+	if r == nil {
+		return
+	}
+	var (
+		src, dst     net.IP
+		sport, dport int
+	)
+	if from != nil {
+		src, sport = from.IP, from.Port
+	}
+	if to != nil {
+		dst, dport = to.IP, to.Port
+	}
+	udp := make([]byte, udpHeaderLen)
+	binary.BigEndian.PutUint16(udp, uint16(sport))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dport))
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpHeaderLen+len(payload)))
+	r.write(when, protoUDP, src, dst, udp, payload)
+}
+
+// Stream returns a reader recording the bytes read from c as tcp segments of
+// one frame each, so that decodeFromPCAP finds a cadu at the start of every
+// segment when the stream is neither sealed nor compressed. The returned
+// function records the bytes left over when the connection ends.
+func (r *recorder) Stream(c net.Conn) (io.Reader, func()) {
+	if r == nil {
+		return c, func() {}
+	}
+	s := &stream{
+		Reader: c,
+		rec:    r,
+		from:   tcpAddr(c.RemoteAddr()),
+		to:     tcpAddr(c.LocalAddr()),
+		seq:    1,
+	}
+	return s, s.Flush
+}
+
+func tcpAddr(a net.Addr) *net.TCPAddr {
+	if t, ok := a.(*net.TCPAddr); ok {
+		return t
+	}
+	return &net.TCPAddr{}
+}
+
+type stream struct {
+	io.Reader
+	rec  *recorder
+	from *net.TCPAddr
+	to   *net.TCPAddr
+	seq  uint32
+	buf  []byte
+}
+
+func (s *stream) Read(bs []byte) (int, error) {
+	n, err := s.Reader.Read(bs)
+	if n > 0 {
+		s.buf = append(s.buf, bs[:n]...)
+		size := Profile.Size()
+		for len(s.buf) >= size {
+			s.segment(s.buf[:size])
+			s.buf = s.buf[size:]
+		}
+	}
+	return n, err
+}
+
+func (s *stream) Flush() {
+	if len(s.buf) > 0 {
+		s.segment(s.buf)
+		s.buf = nil
+	}
+}
+
+func (s *stream) segment(payload []byte) {
+	const (
+		flagPSH = 0x08
+		flagACK = 0x10
+	)
+	tcp := make([]byte, tcpHeaderLen)
+	binary.BigEndian.PutUint16(tcp, uint16(s.from.Port))
+	binary.BigEndian.PutUint16(tcp[2:], uint16(s.to.Port))
+	binary.BigEndian.PutUint32(tcp[4:], s.seq)
+	binary.BigEndian.PutUint32(tcp[8:], 1)
+	tcp[12] = (tcpHeaderLen / 4) << 4
+	tcp[13] = flagPSH | flagACK
+	binary.BigEndian.PutUint16(tcp[14:], 0xffff)
+	// nop, nop and timestamps options filling the header up to tcpHeaderLen
+	tcp[20], tcp[21], tcp[22], tcp[23] = 1, 1, 8, 10
+	binary.BigEndian.PutUint32(tcp[24:], uint32(time.Now().UnixNano()/int64(time.Millisecond)))
+
+	s.rec.write(time.Now(), protoTCP, s.from.IP, s.to.IP, tcp, payload)
+	s.seq += uint32(len(payload))
+}
+
+func (r *recorder) write(when time.Time, proto byte, src, dst net.IP, transport, payload []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return
+	}
+	r.ident++
+
+	var (
+		size = blockLen + len(transport) + len(payload)
+		head = make([]byte, pktHeaderLen+blockLen)
+		eth  = head[pktHeaderLen:]
+		ip   = eth[cookedHeaderLen:]
+	)
+	if size > recordSnapLen {
+		size = recordSnapLen
+	}
+	binary.LittleEndian.PutUint32(head, uint32(when.Unix()))
+	binary.LittleEndian.PutUint32(head[4:], uint32(when.Nanosecond()))
+	binary.LittleEndian.PutUint32(head[8:], uint32(size))
+	binary.LittleEndian.PutUint32(head[12:], uint32(blockLen+len(transport)+len(payload)))
+
+	binary.BigEndian.PutUint16(eth[12:], 0x0800)
+
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(ipHeaderLen+len(transport)+len(payload)))
+	binary.BigEndian.PutUint16(ip[4:], r.ident)
+	ip[8], ip[9] = 64, proto
+	copy(ip[12:16], ipv4(src))
+	copy(ip[16:20], ipv4(dst))
+	binary.BigEndian.PutUint16(ip[10:], ipChecksum(ip[:ipHeaderLen]))
+
+	r.w.Write(head)
+	r.w.Write(transport)
+	if n := size - blockLen - len(transport); n < len(payload) {
+		payload = payload[:n]
+	}
+	r.w.Write(payload)
+}
+
+func ipv4(ip net.IP) net.IP {
+	if v := ip.To4(); v != nil {
+		return v
+	}
+	return net.IPv4zero.To4()
+}
+
+func ipChecksum(bs []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(bs); i += 2 {
+		sum += uint32(bs[i])<<8 | uint32(bs[i+1])
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+func runRelay(args []string) {
+	set := flag.NewFlagSet("relay", flag.ExitOnError)
+	proto := set.String("p", "udp", "protocol")
+	hrdfe := set.Bool("hrdfe", false, "skip byte")
+	scheme := set.String("d", "udp", "protocol of the destinations")
+	skip := set.Bool("crc", false, "drop cadus with an invalid checksum")
+	dry := set.Bool("n", false, "report what would be sent to each destination without sending")
+	seal := set.String("dest-key", "", "file holding the pre-shared aes key (raw or hex) encrypting the cadus sent to destinations")
+	var mopts multicast
+	set.IntVar(&mopts.TTL, "ttl", 0, "multicast ttl")
+	set.StringVar(&mopts.Ifname, "ifname", "", "outgoing interface for multicast destinations")
+	set.StringVar(&mopts.Source, "src", "", "source address for multicast destinations")
+	health := set.String("health", "", "serve health report on address")
+	stale := set.Duration("health-timeout", 10*time.Second, "maximum interval without cadus before reporting unhealthy")
+	alerts := alertFlags(set)
+	profileFlags(set)
+	scanFlags(set)
+	keyFlags(set)
+	fopts := filterFlags(set)
+	pick := set.Duration("select", 0, "with redundant inputs, keep per channel and sequence the first crc-valid copy, waiting up to the duration for one before keeping a corrupted copy")
+	joinFlags(set, "listen-")
+	recordFlags(set)
+	hookFlags(set)
+	clientFlags(set)
+	compressFlags(set)
+	args = parseArgs(set, args)
+
+	if len(args) < 2 {
+		log.Fatalln("relay: source and at least one destination required")
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	st, err := newTracker()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	queue, errc, err := openSource(ctx, *proto, args[:1], *hrdfe)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	hc := NewHealth(*stale)
+	alerts.Health = hc
+	queue = fopts.Filter(ctx, queue)
+	if *pick > 0 {
+		queue = selectBest(ctx, queue, *pick)
+	}
+	queue = alerts.Watch(ctx, queue)
+	failures := watchErrors(errc, hc)
+	if *health != "" {
+		queue = hc.Watch(ctx, queue)
+		serveHealth(*health, hc)
+	}
+
+	aead, err := loadKey(*seal)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var fs fanout
+	for _, a := range args[1:] {
+		d, err := parseDestination(a, *scheme, mopts)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var c net.Conn
+		if *dry {
+			c = dryRun(d.String())
+		} else if c, err = dial(d.Scheme, d.Addr, d.Multicast); err != nil {
+			log.Fatalln(err)
+		}
+		c = sealed(c, aead)
+		fs.Add(a, hc.Writer(a, c), c, d.Pacer())
+	}
+
+	release := !alerts.enabled() && st == nil
+
+	var count, dropped int
+	for c := range st.Track(ctx, queue) {
+		if *skip && c.Error != nil {
+			dropped++
+		} else {
+			fs.Write(c.Bytes())
+			count++
+		}
+		if release {
+			c.Release()
+		}
+	}
+	failed := fs.Close()
+	if err := st.Close(); err != nil {
+		log.Println(err)
+	}
+	closeHooks()
+	if err := Recorder.Close(); err != nil {
+		log.Println(err)
+	}
+	log.Printf("%d cadus relayed (%d dropped, %d failed writes, %d bytes)", count, dropped, failed, count*Profile.Length)
+	alerts.Close()
+	if <-failures > 0 {
+		os.Exit(ExitSource)
+	}
+}
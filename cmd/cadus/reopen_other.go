@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// reopenOnSignal does nothing where SIGUSR1 does not exist.
+func reopenOnSignal(r *rotater) {}
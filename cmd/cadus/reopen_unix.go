@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func reopenOnSignal(r *rotater) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		for range sig {
+			if err := r.Reopen(); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+}
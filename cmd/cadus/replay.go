@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"math"
+	"os"
+	"text/template"
+	"time"
+)
+
+const DefaultReplayTemplate = `{{.Space}};{{.Channel}};{{if .Replay}}pb{{else}}rt{{end}};{{.First}};{{.Last}};{{.Count}};{{.Start.Coarse}}.{{printf "%05d" .Start.Fine}};{{.End.Coarse}}.{{printf "%05d" .End.Fine}};{{.From.Format "2006-002T15:04:05.000"}};{{.To.Format "2006-002T15:04:05.000"}}`
+
+const replayHeader = "scid;vcid;mode;first;last;count;start;end;from;to"
+
+type replayOptions struct {
+	File     string
+	Template string
+	Margin   time.Duration
+}
+
+func replayFlags(set *flag.FlagSet) *replayOptions {
+	o := replayOptions{Template: DefaultReplayTemplate, Margin: time.Second}
+	set.StringVar(&o.File, "replay-request", "", "write a replay request for every missing sequence range to file in gaps mode")
+	set.StringVar(&o.Template, "replay-template", o.Template, "text/template of a replay request line (fields of the json manifest and Start, End as on-board Coarse/Fine, From, To as utc times)")
+	set.DurationVar(&o.Margin, "replay-margin", o.Margin, "widen the replayed time windows by the duration on both sides")
+	return &o
+}
+
+type obt struct {
+	Coarse uint32
+	Fine   uint16
+}
+
+// onboardOf inverts onboardTime with the configured epoch, leap seconds and
+// time correlation.
+func onboardOf(t time.Time) obt {
+	e := time.Time(Epoch)
+	secs := (t.Add(leap() - Offset).Sub(e)).Seconds() / (1 + Drift)
+	if secs < 0 {
+		return obt{}
+	}
+	coarse, frac := math.Modf(secs)
+	return obt{Coarse: uint32(coarse), Fine: uint16(frac * 65536)}
+}
+
+type replayWindow struct {
+	missingRange
+	From  time.Time
+	To    time.Time
+	Start obt
+	End   obt
+}
+
+type replayWriter struct {
+	file  *os.File
+	w     *bufio.Writer
+	tpl   *template.Template
+	mrg   time.Duration
+	count int
+}
+
+func (o *replayOptions) Create() (*replayWriter, error) {
+	tpl, err := template.New("replay").Parse(o.Template)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(o.File)
+	if err != nil {
+		return nil, err
+	}
+	w := replayWriter{file: f, w: bufio.NewWriter(f), tpl: tpl, mrg: o.Margin}
+	if o.Template == DefaultReplayTemplate {
+		w.w.WriteString(replayHeader + "\n")
+	}
+	return &w, nil
+}
+
+func (w *replayWriter) Write(r missingRange) error {
+	x := replayWindow{
+		missingRange: r,
+		From:         r.After.Add(-w.mrg).UTC(),
+		To:           r.Before.Add(w.mrg).UTC(),
+	}
+	x.Start, x.End = onboardOf(x.From), onboardOf(x.To)
+	if err := w.tpl.Execute(w.w, x); err != nil {
+		return err
+	}
+	w.count++
+	return w.w.WriteByte('\n')
+}
+
+func (w *replayWriter) Close() error {
+	err := w.w.Flush()
+	if e := w.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
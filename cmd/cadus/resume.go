@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+var (
+	StartOffset   int64
+	StartSequence = -1
+	ResumeFile    string
+)
+
+const checkpointEvery = 10000
+
+func scanFlags(set *flag.FlagSet) {
+	set.Int64Var(&StartOffset, "from-offset", StartOffset, "byte offset in the first file where decoding starts (rounded down to a frame boundary)")
+	set.IntVar(&StartSequence, "from-seq", StartSequence, "skip cadus until the first one with the given sequence counter")
+	set.StringVar(&ResumeFile, "resume", ResumeFile, "file where the processing state (input offsets, last sequences, counters, output positions) is saved and read back on restart")
+	set.BoolVar(&Progress, "progress", Progress, "report progress and estimated time left of file and pcap scans on stderr")
+	set.StringVar(&WatchDir, "watch", WatchDir, "decode the archive files of the directory, then the new ones as they are closed, instead of the inputs")
+	set.DurationVar(&WatchEvery, "watch-every", WatchEvery, "interval between scans of the watched directory (files unchanged over a scan are considered closed)")
+}
+
+type position struct {
+	File   string
+	Offset int64
+}
+
+type lastSeen struct {
+	cadus.ChannelKey
+	Sequence uint32
+}
+
+type checkpoint struct {
+	File      string              `json:"file"`
+	Offset    int64               `json:"offset"`
+	Since     time.Time           `json:"since"`
+	Inputs    map[string]int64    `json:"inputs,omitempty"`
+	Sequences []lastSeen          `json:"sequences,omitempty"`
+	Counters  map[string]*Summary `json:"counters,omitempty"`
+	Outputs   map[string]int64    `json:"outputs,omitempty"`
+}
+
+func readCheckpoint(file string) (checkpoint, error) {
+	var c checkpoint
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return c, err
+	}
+	str := strings.TrimSpace(string(bs))
+	if strings.HasPrefix(str, "{") {
+		if err := json.Unmarshal(bs, &c); err != nil {
+			return c, fmt.Errorf("%s: %v", file, err)
+		}
+		if c.Offset < 0 {
+			return c, fmt.Errorf("%s: invalid offset %d", file, c.Offset)
+		}
+		return c, nil
+	}
+	ix := strings.LastIndexByte(str, ' ')
+	if ix < 0 {
+		return c, fmt.Errorf("%s: invalid checkpoint %q", file, str)
+	}
+	if _, err := fmt.Sscan(str[ix+1:], &c.Offset); err != nil || c.Offset < 0 {
+		return c, fmt.Errorf("%s: invalid offset %q", file, str[ix+1:])
+	}
+	c.File = str[:ix]
+	return c, nil
+}
+
+func (c checkpoint) Save(file string) error {
+	if file == "" {
+		return nil
+	}
+	bs, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(bs, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+var resumed *checkpoint
+
+// loadState reads the state saved by a previous run once; it is empty when
+// -resume is not given or the file does not exist yet.
+func loadState() (*checkpoint, error) {
+	if resumed != nil {
+		return resumed, nil
+	}
+	var c checkpoint
+	if ResumeFile != "" {
+		var err error
+		if c, err = readCheckpoint(ResumeFile); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	resumed = &c
+	return resumed, nil
+}
+
+func startAt(paths []string) (int, int64, error) {
+	if ResumeFile == "" {
+		return 0, StartOffset, nil
+	}
+	c, err := loadState()
+	if err != nil {
+		return 0, 0, err
+	}
+	if c.File == "" {
+		return 0, StartOffset, nil
+	}
+	for i, p := range paths {
+		if p == c.File {
+			return i, c.Offset, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%s: %s not found in inputs", ResumeFile, c.File)
+}
+
+type tracker struct {
+	cp      checkpoint
+	prevs   map[cadus.ChannelKey]*cadus.Cadu
+	outputs map[string]*rotater
+	resumed bool
+}
+
+// newTracker returns nil when -resume is not given: the methods of a nil
+// tracker do nothing.
+func newTracker() (*tracker, error) {
+	if ResumeFile == "" {
+		return nil, nil
+	}
+	c, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	t := tracker{
+		cp:      *c,
+		prevs:   make(map[cadus.ChannelKey]*cadus.Cadu),
+		outputs: make(map[string]*rotater),
+		resumed: !c.Since.IsZero(),
+	}
+	if t.cp.Since.IsZero() {
+		t.cp.Since = time.Now().UTC()
+	}
+	if t.cp.Inputs == nil {
+		t.cp.Inputs = make(map[string]int64)
+	}
+	if t.cp.Counters == nil {
+		t.cp.Counters = make(map[string]*Summary)
+	}
+	for _, s := range t.cp.Sequences {
+		h := cadus.Header{Space: s.Space, Channel: s.Channel, Replay: s.Replay, Sequence: s.Sequence}
+		t.prevs[s.ChannelKey] = &cadus.Cadu{Header: &h}
+	}
+	return &t, nil
+}
+
+// Output opens file for writing, truncated back to the position saved with
+// the state so that rows of cadus decoded again after a restart are not
+// written twice.
+func (t *tracker) Output(file string, size int64, every time.Duration) (*rotater, error) {
+	var offset int64
+	if t != nil {
+		offset = t.cp.Outputs[file]
+	}
+	r, err := RotateFrom(file, size, every, offset)
+	if err == nil && t != nil {
+		t.outputs[file] = r
+	}
+	return r, err
+}
+
+// Track updates the state with every cadu going through it and saves it every
+// checkpointEvery cadus.
+func (t *tracker) Track(ctx context.Context, queue <-chan *TimeCadu) <-chan *TimeCadu {
+	if t == nil {
+		return queue
+	}
+	q := make(chan *TimeCadu)
+	go func() {
+		defer close(q)
+
+		var count int
+		for c := range queue {
+			if !send(ctx, q, c) {
+				return
+			}
+			t.Update(c)
+			if count++; count%checkpointEvery == 0 {
+				if err := t.Save(); err != nil {
+					log.Printf("[resume] %s", err)
+				}
+			}
+		}
+	}()
+	return q
+}
+
+func (t *tracker) Update(c *TimeCadu) {
+	if c.pos.File != "" {
+		t.cp.File, t.cp.Offset = c.pos.File, c.pos.Offset
+		t.cp.Inputs[c.pos.File] = c.pos.Offset
+	}
+	s, ok := t.cp.Counters[c.Source]
+	if !ok {
+		s = &Summary{}
+		t.cp.Counters[c.Source] = s
+	}
+	k := c.Key()
+	p := t.prevs[k]
+	if p != nil {
+		s.Update(c, c.Cadu.Missing(p))
+	} else {
+		s.Update(c, 0)
+	}
+	if c.Duplicate {
+		return
+	}
+	if p == nil {
+		h := cadus.Header{Space: k.Space, Channel: k.Channel, Replay: k.Replay}
+		p = &cadus.Cadu{Header: &h}
+		t.prevs[k] = p
+	}
+	p.Sequence = c.Sequence
+}
+
+func (t *tracker) Save() error {
+	if t == nil {
+		return nil
+	}
+	t.cp.Sequences = t.cp.Sequences[:0]
+	for k, p := range t.prevs {
+		t.cp.Sequences = append(t.cp.Sequences, lastSeen{ChannelKey: k, Sequence: p.Sequence})
+	}
+	sort.Slice(t.cp.Sequences, func(i, j int) bool {
+		return t.cp.Sequences[i].String() < t.cp.Sequences[j].String()
+	})
+	if len(t.outputs) > 0 {
+		t.cp.Outputs = make(map[string]int64)
+		for f, r := range t.outputs {
+			t.cp.Outputs[f] = r.Position()
+		}
+	}
+	return t.cp.Save(ResumeFile)
+}
+
+// Close saves the final state once the consumer of the tracked queue is done
+// and logs the counters accumulated over the runs sharing the state.
+func (t *tracker) Close() error {
+	if t == nil {
+		return nil
+	}
+	if t.resumed {
+		names := make([]string, 0, len(t.cp.Counters))
+		for n := range t.cp.Counters {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			if n == "" {
+				log.Printf("[resume] since %s: %s", formatTime(t.cp.Since), t.cp.Counters[n])
+			} else {
+				log.Printf("[resume] %s since %s: %s", n, formatTime(t.cp.Since), t.cp.Counters[n])
+			}
+		}
+	}
+	return t.Save()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const ringSlotLen = 9 << 10
+
+var RingSlots = 4096
+
+type slot struct {
+	buf  []byte
+	oob  []byte
+	n    int
+	oobn int
+	from *net.UDPAddr
+	when time.Time
+}
+
+type ring struct {
+	slots  []slot
+	head   uint64
+	tail   uint64
+	closed int32
+	bell   chan struct{}
+}
+
+func newRing(size int) *ring {
+	r := ring{
+		slots: make([]slot, size),
+		bell:  make(chan struct{}, 1),
+	}
+	for i := range r.slots {
+		r.slots[i].buf = make([]byte, ringSlotLen)
+		r.slots[i].oob = make([]byte, 128)
+	}
+	return &r
+}
+
+func (r *ring) Reserve() *slot {
+	h := atomic.LoadUint64(&r.head)
+	if h-atomic.LoadUint64(&r.tail) >= uint64(len(r.slots)) {
+		return nil
+	}
+	return &r.slots[h%uint64(len(r.slots))]
+}
+
+func (r *ring) Publish() {
+	atomic.AddUint64(&r.head, 1)
+	r.ring()
+}
+
+func (r *ring) Next() *slot {
+	for {
+		t := atomic.LoadUint64(&r.tail)
+		if t < atomic.LoadUint64(&r.head) {
+			return &r.slots[t%uint64(len(r.slots))]
+		}
+		if atomic.LoadInt32(&r.closed) == 1 {
+			return nil
+		}
+		<-r.bell
+	}
+}
+
+func (r *ring) Release() {
+	atomic.AddUint64(&r.tail, 1)
+}
+
+func (r *ring) Close() {
+	atomic.StoreInt32(&r.closed, 1)
+	r.ring()
+}
+
+func (r *ring) ring() {
+	select {
+	case r.bell <- struct{}{}:
+	default:
+	}
+}
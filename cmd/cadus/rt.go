@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	rtInterval = 5 * time.Minute
+	rtPattern  = "%Y/%j/%H/rt_%M"
+)
+
+type rtWriter struct {
+	net.Conn
+	base  string
+	every time.Duration
+	end   time.Time
+	inner *os.File
+}
+
+func rollRT(base string, every time.Duration) (*rtWriter, error) {
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	return &rtWriter{base: base, every: every}, nil
+}
+
+func (w *rtWriter) Write(bs []byte) (int, error) {
+	if now := time.Now().UTC(); w.inner == nil || !now.Before(w.end) {
+		if err := w.open(now); err != nil {
+			return 0, err
+		}
+	}
+	return w.inner.Write(bs)
+}
+
+func (w *rtWriter) Close() error {
+	if w.inner == nil {
+		return nil
+	}
+	return w.inner.Close()
+}
+
+func (w *rtWriter) open(now time.Time) error {
+	if w.inner != nil {
+		if err := w.inner.Close(); err != nil {
+			return err
+		}
+	}
+	start := now.Truncate(w.every)
+	w.end = start.Add(w.every)
+
+	dir := filepath.Join(w.base, fmt.Sprintf("%04d", start.Year()), fmt.Sprintf("%03d", start.YearDay()), fmt.Sprintf("%02d", start.Hour()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	last := w.end.Add(-time.Minute)
+	file := filepath.Join(dir, fmt.Sprintf("rt_%02d_%02d.dat", start.Minute(), last.Minute()))
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.inner = f
+	return nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rudp carries the datagrams of udp behind a header giving their kind and
+// sequence. Receivers deliver the datagrams in order and send back a nak
+// with the sequences missing, resent by the sender as long as they are in
+// its window. Sequences still missing after RUDPLatency are given up.
+const (
+	rudpData byte = 1
+	rudpNak  byte = 2
+
+	rudpHeaderLen = 5
+	rudpNakMax    = 256
+)
+
+var (
+	RUDPLatency = 500 * time.Millisecond
+	RUDPWindow  = 8192
+)
+
+type rudpConn struct {
+	*net.UDPConn
+
+	mu     sync.Mutex
+	seq    uint32
+	sent   [][]byte
+	resent int
+	done   chan struct{}
+}
+
+func dialRUDP(addr string) (net.Conn, error) {
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := &rudpConn{
+		UDPConn: c.(*net.UDPConn),
+		sent:    make([][]byte, RUDPWindow),
+		done:    make(chan struct{}),
+	}
+	go r.serveNaks()
+	return r, nil
+}
+
+func (r *rudpConn) Write(bs []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	x := int(r.seq % uint32(len(r.sent)))
+	vs := append(r.sent[x][:0], rudpData, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(vs[1:], r.seq)
+	vs = append(vs, bs...)
+	r.sent[x] = vs
+	r.seq++
+
+	if _, err := r.UDPConn.Write(vs); err != nil {
+		return 0, err
+	}
+	return len(bs), nil
+}
+
+func (r *rudpConn) Close() error {
+	err := r.UDPConn.Close()
+	<-r.done
+	if r.resent > 0 {
+		log.Printf("[rudp] %s: %d datagrams resent", r.RemoteAddr(), r.resent)
+	}
+	return err
+}
+
+func (r *rudpConn) serveNaks() {
+	defer close(r.done)
+	bs := make([]byte, rudpHeaderLen+4*rudpNakMax)
+	for {
+		n, err := r.UDPConn.Read(bs)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil || n < rudpHeaderLen || bs[0] != rudpNak {
+			continue
+		}
+		r.mu.Lock()
+		for vs := bs[rudpHeaderLen:n]; len(vs) >= 4; vs = vs[4:] {
+			seq := binary.BigEndian.Uint32(vs)
+			if int32(r.seq-seq) <= 0 || r.seq-seq > uint32(len(r.sent)) {
+				continue
+			}
+			if _, err := r.UDPConn.Write(r.sent[seq%uint32(len(r.sent))]); err == nil {
+				r.resent++
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+type rudpDatagram struct {
+	Data []byte
+	When time.Time
+}
+
+// rudpPeer orders the datagrams received from a sender. next is the sequence
+// delivered next and high the sequence following the highest received.
+type rudpPeer struct {
+	next    uint32
+	high    uint32
+	started bool
+
+	pending map[uint32]rudpDatagram
+	missing map[uint32]time.Time
+	lost    int
+}
+
+func newPeer() *rudpPeer {
+	return &rudpPeer{
+		pending: make(map[uint32]rudpDatagram),
+		missing: make(map[uint32]time.Time),
+	}
+}
+
+// Push adds the datagram seq to p and returns the datagrams that can be
+// delivered in order and the sequences newly missing.
+func (p *rudpPeer) Push(seq uint32, data []byte, when time.Time) ([]rudpDatagram, []uint32) {
+	if d := int32(seq - p.next); !p.started || d < -int32(RUDPWindow) || d >= int32(RUDPWindow) {
+		// first datagram or restarted sender
+		p.lost += len(p.missing)
+		p.next, p.high, p.started = seq, seq, true
+		p.pending = make(map[uint32]rudpDatagram)
+		p.missing = make(map[uint32]time.Time)
+	}
+	if int32(seq-p.next) < 0 {
+		return nil, nil
+	}
+	if _, ok := p.pending[seq]; ok {
+		return nil, nil
+	}
+	delete(p.missing, seq)
+
+	var naks []uint32
+	if int32(seq-p.high) >= 0 {
+		for s := p.high; s != seq; s++ {
+			p.missing[s] = when
+			naks = append(naks, s)
+		}
+		p.high = seq + 1
+	}
+	p.pending[seq] = rudpDatagram{Data: append([]byte(nil), data...), When: when}
+	return p.drain(when), naks
+}
+
+// Expire gives up on the sequences missing for longer than RUDPLatency and
+// returns the datagrams then delivered and the sequences still missing.
+func (p *rudpPeer) Expire(now time.Time) ([]rudpDatagram, []uint32) {
+	ready := p.drain(now)
+	naks := make([]uint32, 0, len(p.missing))
+	for s := range p.missing {
+		naks = append(naks, s)
+	}
+	sort.Slice(naks, func(i, j int) bool { return int32(naks[i]-p.next) < int32(naks[j]-p.next) })
+	return ready, naks
+}
+
+func (p *rudpPeer) drain(now time.Time) []rudpDatagram {
+	var ready []rudpDatagram
+	for p.next != p.high {
+		if d, ok := p.pending[p.next]; ok {
+			ready = append(ready, d)
+			delete(p.pending, p.next)
+		} else if t, ok := p.missing[p.next]; ok && now.Sub(t) >= RUDPLatency {
+			delete(p.missing, p.next)
+			p.lost++
+		} else {
+			break
+		}
+		p.next++
+	}
+	return ready
+}
+
+func decodeFromRUDP(ctx context.Context, g *group, addr string) (<-chan *TimeCadu, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r, err := net.ListenUDP("udp", a)
+	if err != nil {
+		return nil, err
+	}
+	q := make(chan *TimeCadu, 100)
+	g.Go(func() error {
+		return decodeReliable(ctx, r, q)
+	})
+	return q, nil
+}
+
+func decodeReliable(ctx context.Context, r *net.UDPConn, q chan<- *TimeCadu) error {
+	var (
+		peers    = make(map[string]*rudpPeer)
+		addrs    = make(map[string]*net.UDPAddr)
+		rejected = make(map[string]int)
+		local, _ = r.LocalAddr().(*net.UDPAddr)
+		bs       = make([]byte, ringSlotLen)
+		every    = RUDPLatency / 4
+		last     = time.Now()
+	)
+	defer func() {
+		close(q)
+		r.Close()
+		for a, p := range peers {
+			if n := p.lost + len(p.missing); n > 0 {
+				log.Printf("[rudp] %s: %d datagrams lost", a, n)
+			}
+		}
+		for a, n := range rejected {
+			log.Printf("[rudp] %s: %d datagrams rejected", a, n)
+		}
+	}()
+	defer closeOnDone(ctx, r)()
+
+	nak := func(to *net.UDPAddr, seqs []uint32) {
+		for len(seqs) > 0 {
+			n := len(seqs)
+			if n > rudpNakMax {
+				n = rudpNakMax
+			}
+			vs := make([]byte, rudpHeaderLen+4*n)
+			vs[0] = rudpNak
+			for i, s := range seqs[:n] {
+				binary.BigEndian.PutUint32(vs[rudpHeaderLen+4*i:], s)
+			}
+			r.WriteToUDP(vs, to)
+			seqs = seqs[n:]
+		}
+	}
+	deliver := func(sender string, ds []rudpDatagram) bool {
+		for _, d := range ds {
+			vs := d.Data
+			if Sealer != nil {
+				var err error
+				if vs, err = unsealDatagram(vs); err != nil {
+					log.Printf("[rudp] %s: %s", sender, err)
+					continue
+				}
+			}
+			if !decodeDatagram(ctx, q, vs, d.When, sender) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for {
+		if every > 0 {
+			r.SetReadDeadline(time.Now().Add(every))
+		}
+		n, from, err := r.ReadFromUDP(bs)
+		if err != nil {
+			var ne net.Error
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				return fmt.Errorf("%s: %v", r.LocalAddr(), err)
+			}
+		}
+		now := time.Now()
+		if err == nil && n >= rudpHeaderLen && bs[0] == rudpData {
+			sender := from.String()
+			if !Allow.Has(from.IP) {
+				if rejected[sender] == 0 {
+					log.Printf("[rudp] %s: sender not allowed, rejecting its datagrams", sender)
+				}
+				rejected[sender]++
+				continue
+			}
+			Recorder.Datagram(now, from, local, bs[rudpHeaderLen:n])
+			p, ok := peers[sender]
+			if !ok {
+				p = newPeer()
+				peers[sender], addrs[sender] = p, from
+			}
+			ready, naks := p.Push(binary.BigEndian.Uint32(bs[1:]), bs[rudpHeaderLen:n], now)
+			nak(from, naks)
+			if !deliver(sender, ready) {
+				return nil
+			}
+		}
+		if now.Sub(last) < every {
+			continue
+		}
+		last = now
+		for sender, p := range peers {
+			ready, naks := p.Expire(now)
+			nak(addrs[sender], naks)
+			if !deliver(sender, ready) {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+func TestRUDPPeer(t *testing.T) {
+	type push struct {
+		Seq   uint32
+		After time.Duration
+		Ready []uint32
+		Naks  []uint32
+	}
+	data := []struct {
+		Name   string
+		Pushes []push
+		Expire time.Duration
+		Ready  []uint32
+		Naks   []uint32
+		Lost   int
+	}{
+		{
+			Name:   "in-order",
+			Pushes: []push{{Seq: 10, Ready: []uint32{10}}, {Seq: 11, Ready: []uint32{11}}, {Seq: 12, Ready: []uint32{12}}},
+		},
+		{
+			Name:   "reordered",
+			Pushes: []push{{Seq: 1, Ready: []uint32{1}}, {Seq: 4, Naks: []uint32{2, 3}}, {Seq: 3}, {Seq: 2, Ready: []uint32{2, 3, 4}}},
+		},
+		{
+			Name:   "duplicated",
+			Pushes: []push{{Seq: 1, Ready: []uint32{1}}, {Seq: 3, Naks: []uint32{2}}, {Seq: 3}, {Seq: 1}},
+			Naks:   []uint32{2},
+		},
+		{
+			Name:   "lost",
+			Pushes: []push{{Seq: 1, Ready: []uint32{1}}, {Seq: 4, Naks: []uint32{2, 3}}, {Seq: 3, After: time.Millisecond}},
+			Expire: 2 * RUDPLatency,
+			Ready:  []uint32{3, 4},
+			Lost:   1,
+		},
+		{
+			Name:   "wrapped",
+			Pushes: []push{{Seq: 0xFFFFFFFF, Ready: []uint32{0xFFFFFFFF}}, {Seq: 1, Naks: []uint32{0}}, {Seq: 0, Ready: []uint32{0, 1}}},
+		},
+		{
+			Name:   "restarted",
+			Pushes: []push{{Seq: 50000, Ready: []uint32{50000}}, {Seq: 50002, Naks: []uint32{50001}}, {Seq: 0, Ready: []uint32{0}}},
+			Lost:   1,
+		},
+	}
+	seqs := func(ds []rudpDatagram) []uint32 {
+		var vs []uint32
+		for _, d := range ds {
+			vs = append(vs, uint32(d.Data[0])<<24|uint32(d.Data[1])<<16|uint32(d.Data[2])<<8|uint32(d.Data[3]))
+		}
+		return vs
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			var (
+				p    = newPeer()
+				when = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+			)
+			for _, u := range d.Pushes {
+				when = when.Add(u.After)
+				ready, naks := p.Push(u.Seq, []byte{byte(u.Seq >> 24), byte(u.Seq >> 16), byte(u.Seq >> 8), byte(u.Seq)}, when)
+				if got := seqs(ready); !reflect.DeepEqual(got, u.Ready) {
+					t.Fatalf("push %d: want %v delivered, got %v", u.Seq, u.Ready, got)
+				}
+				if !reflect.DeepEqual(naks, u.Naks) {
+					t.Fatalf("push %d: want %v missing, got %v", u.Seq, u.Naks, naks)
+				}
+			}
+			ready, naks := p.Expire(when.Add(d.Expire))
+			if got := seqs(ready); !reflect.DeepEqual(got, d.Ready) {
+				t.Errorf("expire: want %v delivered, got %v", d.Ready, got)
+			}
+			if len(naks) == 0 {
+				naks = nil
+			}
+			if !reflect.DeepEqual(naks, d.Naks) {
+				t.Errorf("expire: want %v missing, got %v", d.Naks, naks)
+			}
+			if p.lost != d.Lost {
+				t.Errorf("want %d lost, got %d", d.Lost, p.lost)
+			}
+		})
+	}
+}
+
+// lossyProxy forwards the datagrams between the sender and to, dropping the
+// first copy of the data datagram drop.
+func lossyProxy(t *testing.T, to net.Addr, drop int) *net.UDPConn {
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %s", err)
+	}
+	dst := to.(*net.UDPAddr)
+	go func() {
+		var (
+			bs     = make([]byte, 64<<10)
+			sender *net.UDPAddr
+			count  int
+		)
+		for {
+			n, from, err := c.ReadFromUDP(bs)
+			if err != nil {
+				return
+			}
+			if from.String() == dst.String() {
+				if sender != nil {
+					c.WriteToUDP(bs[:n], sender)
+				}
+				continue
+			}
+			sender = from
+			if count++; count == drop {
+				continue
+			}
+			c.WriteToUDP(bs[:n], dst)
+		}
+	}()
+	return c
+}
+
+func TestRUDPResend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %s", err)
+	}
+	q := make(chan *TimeCadu, 100)
+	go decodeReliable(ctx, r, q)
+
+	proxy := lossyProxy(t, r.LocalAddr(), 4)
+	defer proxy.Close()
+
+	w, err := dialRUDP(proxy.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %s", err)
+	}
+	defer w.Close()
+
+	const count = 10
+	for i := 0; i < count; i++ {
+		c := cadus.Cadu{Header: &cadus.Header{Word: 0x1acffc1d, Version: 1, Space: 0x23, Channel: 1, Sequence: uint32(i)}, Payload: cadus.IdlePayload}
+		if _, err := w.Write(Profile.Encode(&c)); err != nil {
+			t.Fatalf("unexpected error writing: %s", err)
+		}
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case c := <-q:
+			if c.Sequence != uint32(i) {
+				t.Fatalf("want cadu %d, got %d", i, c.Sequence)
+			}
+			c.Release()
+		case <-time.After(RUDPLatency):
+			t.Fatalf("cadu %d not received", i)
+		}
+	}
+}
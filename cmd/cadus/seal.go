@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+var errUnsealed = errors.New("truncated sealed record")
+
+var (
+	KeyFile string
+	Sealer  cipher.AEAD
+)
+
+func keyFlags(set *flag.FlagSet) {
+	set.StringVar(&KeyFile, "key", KeyFile, "file holding the pre-shared aes key (raw or hex) decrypting udp and tcp sources")
+}
+
+func loadKey(file string) (cipher.AEAD, error) {
+	if file == "" {
+		return nil, nil
+	}
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if k, err := hex.DecodeString(strings.TrimSpace(string(bs))); err == nil {
+		bs = k
+	}
+	b, err := aes.NewCipher(bs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return cipher.NewGCM(b)
+}
+
+type sealConn struct {
+	net.Conn
+	aead  cipher.AEAD
+	nonce []byte
+	count uint64
+}
+
+func sealed(c net.Conn, aead cipher.AEAD) net.Conn {
+	if aead == nil {
+		return c
+	}
+	s := sealConn{
+		Conn:  c,
+		aead:  aead,
+		nonce: make([]byte, aead.NonceSize()),
+	}
+	rand.Read(s.nonce[:len(s.nonce)-8])
+	return &s
+}
+
+func (c *sealConn) Write(bs []byte) (int, error) {
+	c.count++
+	binary.BigEndian.PutUint64(c.nonce[len(c.nonce)-8:], c.count)
+
+	vs := make([]byte, 2, 2+len(c.nonce)+len(bs)+c.aead.Overhead())
+	vs = append(vs, c.nonce...)
+	vs = c.aead.Seal(vs, c.nonce, bs, nil)
+	binary.BigEndian.PutUint16(vs, uint16(len(vs)-2))
+	if _, err := c.Conn.Write(vs); err != nil {
+		return 0, err
+	}
+	return len(bs), nil
+}
+
+func unseal(aead cipher.AEAD, bs []byte) ([]byte, []byte, error) {
+	if len(bs) < 2 {
+		return nil, nil, errUnsealed
+	}
+	n := int(binary.BigEndian.Uint16(bs))
+	if bs = bs[2:]; n > len(bs) || n < aead.NonceSize() {
+		return nil, nil, errUnsealed
+	}
+	z := aead.NonceSize()
+	vs, err := aead.Open(nil, bs[:z], bs[z:n], nil)
+	return vs, bs[n:], err
+}
+
+type unsealReader struct {
+	inner  *bufio.Reader
+	aead   cipher.AEAD
+	buffer []byte
+}
+
+func unsealed(r io.Reader, aead cipher.AEAD) io.Reader {
+	if aead == nil {
+		return r
+	}
+	return &unsealReader{inner: bufio.NewReader(r), aead: aead}
+}
+
+func (r *unsealReader) Read(bs []byte) (int, error) {
+	if len(r.buffer) == 0 {
+		head := make([]byte, 2)
+		if _, err := io.ReadFull(r.inner, head); err != nil {
+			return 0, err
+		}
+		vs := make([]byte, 2+int(binary.BigEndian.Uint16(head)))
+		copy(vs, head)
+		if _, err := io.ReadFull(r.inner, vs[2:]); err != nil {
+			return 0, err
+		}
+		xs, _, err := unseal(r.aead, vs)
+		if err != nil {
+			return 0, err
+		}
+		r.buffer = xs
+	}
+	n := copy(bs, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+func unsealDatagram(bs []byte) ([]byte, error) {
+	var vs []byte
+	for len(bs) > 0 {
+		xs, rest, err := unseal(Sealer, bs)
+		if err != nil {
+			return nil, err
+		}
+		vs, bs = append(vs, xs...), rest
+	}
+	return vs, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+type selectKey struct {
+	cadus.ChannelKey
+	Sequence uint32
+}
+
+type candidate struct {
+	key  selectKey
+	cadu *TimeCadu
+	due  time.Time
+}
+
+type chainStats struct {
+	Count     int
+	Corrupted int
+	Selected  int
+}
+
+// selectBest merges the copies of the same downlink received through several
+// inputs: it forwards, per channel and sequence, the first copy with a valid
+// crc, waiting up to window for one before keeping the first corrupted copy.
+func selectBest(ctx context.Context, queue <-chan *TimeCadu, window time.Duration) <-chan *TimeCadu {
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+
+		var (
+			pending []*candidate
+			index   = make(map[selectKey]*candidate)
+			done    = make(map[selectKey]time.Time)
+			chains  = make(map[string]*chainStats)
+			dropped int
+			every   = window / 2
+		)
+		if every < 10*time.Millisecond {
+			every = 10 * time.Millisecond
+		}
+		tick := time.NewTicker(every)
+		defer tick.Stop()
+
+		stats := func(source string) *chainStats {
+			s, ok := chains[source]
+			if !ok {
+				s = &chainStats{}
+				chains[source] = s
+			}
+			return s
+		}
+		release := func(all bool) bool {
+			now := time.Now()
+			for len(pending) > 0 {
+				x := pending[0]
+				if !all && x.cadu.Error != nil && now.Before(x.due) {
+					break
+				}
+				pending = pending[1:]
+				delete(index, x.key)
+				done[x.key] = now
+				stats(x.cadu.Source).Selected++
+				if !send(ctx, q, x.cadu) {
+					return false
+				}
+			}
+			return true
+		}
+		defer func() {
+			names := make([]string, 0, len(chains))
+			for n := range chains {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			for _, n := range names {
+				s := chains[n]
+				log.Printf("[select] %s: %d cadus selected (%d received, %d corrupted)", n, s.Selected, s.Count, s.Corrupted)
+			}
+			log.Printf("[select] %d redundant copies dropped", dropped)
+		}()
+		for {
+			select {
+			case c, ok := <-queue:
+				if !ok {
+					release(true)
+					return
+				}
+				s := stats(c.Source)
+				if s.Count++; c.Error != nil {
+					s.Corrupted++
+				}
+				k := selectKey{ChannelKey: c.Key(), Sequence: c.Sequence}
+				if x, ok := index[k]; ok {
+					if x.cadu.Error != nil && c.Error == nil {
+						x.cadu, c = c, x.cadu
+					}
+					dropped++
+					c.Release()
+				} else if _, ok := done[k]; ok {
+					dropped++
+					c.Release()
+				} else {
+					x := candidate{key: k, cadu: c, due: time.Now().Add(window)}
+					pending = append(pending, &x)
+					index[k] = &x
+				}
+				if !release(false) {
+					return
+				}
+			case <-tick.C:
+				if !release(false) {
+					return
+				}
+				limit := time.Now().Add(-10 * window)
+				for k, w := range done {
+					if w.Before(limit) {
+						delete(done, k)
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return q
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultBaseOID = ".1.3.6.1.4.1.8072.9999.1"
+
+type oid []int
+
+func parseOID(str string) (oid, error) {
+	str = strings.Trim(strings.TrimSpace(str), ".")
+	if str == "" {
+		return nil, fmt.Errorf("empty oid")
+	}
+	var o oid
+	for _, s := range strings.Split(str, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: invalid oid", str)
+		}
+		o = append(o, n)
+	}
+	return o, nil
+}
+
+func (o oid) String() string {
+	var str strings.Builder
+	for _, n := range o {
+		str.WriteByte('.')
+		str.WriteString(strconv.Itoa(n))
+	}
+	return str.String()
+}
+
+func (o oid) Child(n int) oid {
+	return append(append(oid{}, o...), n)
+}
+
+func (o oid) Compare(x oid) int {
+	for i := 0; i < len(o) && i < len(x); i++ {
+		if o[i] != x[i] {
+			if o[i] < x[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(o) - len(x)
+}
+
+type snmpVar struct {
+	OID   oid
+	Type  string
+	Value func(healthReport) string
+}
+
+func linkVars(base oid) []snmpVar {
+	return []snmpVar{
+		{OID: base.Child(1), Type: "counter64", Value: func(z healthReport) string { return strconv.Itoa(z.Count) }},
+		{OID: base.Child(2), Type: "counter64", Value: func(z healthReport) string { return strconv.FormatUint(z.Missing, 10) }},
+		{OID: base.Child(3), Type: "counter64", Value: func(z healthReport) string { return strconv.Itoa(z.Corrupted) }},
+		{OID: base.Child(4), Type: "gauge", Value: func(z healthReport) string { return strconv.Itoa(int(math.Round(z.Rate))) }},
+		{OID: base.Child(5), Type: "integer", Value: func(z healthReport) string {
+			if z.Status == "ok" {
+				return "1"
+			}
+			return "2"
+		}},
+	}
+}
+
+// runSNMP answers the requests of snmpd on stdin with the pass_persist
+// protocol. The counters are read from the health endpoint of a running list,
+// build or relay, e.g. in snmpd.conf:
+//
+//	pass_persist .1.3.6.1.4.1.8072.9999.1 /usr/bin/cadus snmp -url http://localhost:8080/healthz
+func runSNMP(args []string) {
+	set := flag.NewFlagSet("snmp", flag.ExitOnError)
+	url := set.String("url", "http://localhost:8080/healthz", "health endpoint of the monitored cadus process")
+	base := set.String("oid", DefaultBaseOID, "base oid of the link counters (.1 cadus, .2 missing, .3 corrupted, .4 cadus/s, .5 status: 1 ok, 2 failing)")
+	cache := set.Duration("cache", time.Second, "interval during which the counters fetched are reused across requests")
+	timeout := set.Duration("timeout", 2*time.Second, "timeout of the requests to the health endpoint")
+	set.Parse(args)
+
+	// stdout belongs to snmpd
+	log.SetOutput(os.Stderr)
+
+	b, err := parseOID(*base)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var (
+		vars   = linkVars(b)
+		client = http.Client{Timeout: *timeout}
+		last   healthReport
+		when   time.Time
+	)
+	fetch := func() (healthReport, error) {
+		if !when.IsZero() && time.Since(when) < *cache {
+			return last, nil
+		}
+		rs, err := client.Get(*url)
+		if err != nil {
+			return last, err
+		}
+		defer rs.Body.Close()
+		var z healthReport
+		if err := json.NewDecoder(rs.Body).Decode(&z); err != nil {
+			return last, fmt.Errorf("%s: %v", *url, err)
+		}
+		last, when = z, time.Now()
+		return z, nil
+	}
+
+	var (
+		rs = bufio.NewScanner(os.Stdin)
+		ws = bufio.NewWriter(os.Stdout)
+	)
+	next := func() (string, bool) {
+		if !rs.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(rs.Text()), true
+	}
+	for {
+		cmd, ok := next()
+		if !ok || cmd == "" {
+			return
+		}
+		switch strings.ToLower(cmd) {
+		case "ping":
+			ws.WriteString("PONG\n")
+		case "get", "getnext":
+			str, ok := next()
+			if !ok {
+				return
+			}
+			o, err := parseOID(str)
+			if err != nil {
+				ws.WriteString("NONE\n")
+				break
+			}
+			v, ok := lookupVar(vars, o, cmd != "get")
+			if !ok {
+				ws.WriteString("NONE\n")
+				break
+			}
+			z, err := fetch()
+			if err != nil {
+				log.Println(err)
+				ws.WriteString("NONE\n")
+				break
+			}
+			fmt.Fprintf(ws, "%s\n%s\n%s\n", v.OID, v.Type, v.Value(z))
+		case "set":
+			next()
+			next()
+			ws.WriteString("not-writable\n")
+		default:
+			ws.WriteString("NONE\n")
+		}
+		if err := ws.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func lookupVar(vars []snmpVar, o oid, after bool) (snmpVar, bool) {
+	for _, v := range vars {
+		c := v.OID.Compare(o)
+		if (!after && c == 0) || (after && c > 0) {
+			return v, true
+		}
+	}
+	return snmpVar{}, false
+}
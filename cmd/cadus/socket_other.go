@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// msgTrunc is unknown here: truncated datagrams are not counted.
+const msgTrunc = 0
+
+func multicastControl(v6 bool, ttl, index int, ifaddr net.IP) func(string, string, syscall.RawConn) error {
+	if ttl <= 0 && index <= 0 && ifaddr == nil {
+		return nil
+	}
+	return func(_, _ string, _ syscall.RawConn) error {
+		return fmt.Errorf("multicast ttl and interface not supported on this platform")
+	}
+}
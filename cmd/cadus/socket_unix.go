@@ -0,0 +1,43 @@
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// msgTrunc is the flag set on truncated datagrams by ReadMsgUDP.
+const msgTrunc = syscall.MSG_TRUNC
+
+// multicastControl sets on the socket of a multicast destination the ttl and
+// the outgoing interface, given by its index in ipv6 and by its address in
+// ipv4.
+func multicastControl(v6 bool, ttl, index int, ifaddr net.IP) func(string, string, syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		var err error
+		cerr := c.Control(func(fd uintptr) {
+			if v6 {
+				if ttl > 0 {
+					err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, ttl)
+				}
+				if err == nil && index > 0 {
+					err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_IF, index)
+				}
+				return
+			}
+			if ttl > 0 {
+				err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, ttl)
+			}
+			if ip := ifaddr.To4(); err == nil && ip != nil {
+				var a [4]byte
+				copy(a[:], ip)
+				err = syscall.SetsockoptInet4Addr(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, a)
+			}
+		})
+		if cerr != nil {
+			return cerr
+		}
+		return err
+	}
+}
@@ -0,0 +1,1139 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+const (
+	pcapHeaderLen   = 24
+	pktHeaderLen    = 16
+	cookedHeaderLen = 14
+	ipHeaderLen     = 20
+	udpHeaderLen    = 8
+	tcpHeaderLen    = 32
+	// tcpHeaderLen    = 20
+	blockLen = cookedHeaderLen + ipHeaderLen
+	// blockLen = pktHeaderLen + cookedHeaderLen + ipHeaderLen + udpHeaderLen
+)
+
+var KernelTime bool
+
+type TimeCadu struct {
+	*cadus.Cadu
+	Reception time.Time
+	Duplicate bool
+	Resumed   bool
+	Backward  uint32
+	Source    string
+	Sender    string
+
+	pos position
+}
+
+func (t *TimeCadu) Missing(p *TimeCadu) uint32 {
+	if p == nil || t.Duplicate || t.Resumed {
+		return 0
+	}
+	return t.Cadu.Missing(p.Cadu)
+}
+
+func (t *TimeCadu) Elapsed(p *TimeCadu) time.Duration {
+	if p == nil {
+		return 0
+	}
+	if p.Reception.After(t.Reception) {
+		return p.Elapsed(t)
+	}
+	return t.Reception.Sub(p.Reception)
+}
+
+func send(ctx context.Context, q chan<- *TimeCadu, c *TimeCadu) bool {
+	select {
+	case q <- c:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+type group struct {
+	ctx  context.Context
+	wg   sync.WaitGroup
+	errs chan error
+}
+
+func newGroup(ctx context.Context) *group {
+	return &group{ctx: ctx, errs: make(chan error, 100)}
+}
+
+func (g *group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		g.Report(f())
+	}()
+}
+
+func (g *group) Report(err error) {
+	if err == nil || g.ctx.Err() != nil {
+		return
+	}
+	g.errs <- err
+}
+
+func (g *group) Errors() <-chan error {
+	go func() {
+		g.wg.Wait()
+		close(g.errs)
+	}()
+	return g.errs
+}
+
+func watchErrors(errs <-chan error, hc *Health) <-chan int {
+	q := make(chan int, 1)
+	go func() {
+		var n int
+		for err := range errs {
+			n++
+			log.Printf("[source] %s", err)
+			if hc != nil {
+				hc.Report("source", err)
+			}
+		}
+		q <- n
+	}()
+	return q
+}
+
+func closeOnDone(ctx context.Context, c io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func decodeFromTCP(ctx context.Context, g *group, addr, codec string) (<-chan *TimeCadu, error) {
+	c, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	q := make(chan *TimeCadu, 100)
+	g.Go(func() error {
+		var wg sync.WaitGroup
+		defer func() {
+			c.Close()
+			wg.Wait()
+			close(q)
+		}()
+		defer closeOnDone(ctx, c)()
+		for {
+			c, err := c.Accept()
+			if err != nil {
+				return fmt.Errorf("%s: %v", addr, err)
+			}
+			x := registry.Accept(addr, c)
+			if x == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(c net.Conn) {
+				defer wg.Done()
+				defer registry.Done(addr, x)
+				defer c.Close()
+				defer closeOnDone(ctx, c)()
+				raw, flush := Recorder.Stream(c)
+				defer flush()
+				rs := newReader(unsealed(decompressed(raw, codec), Sealer))
+				for {
+					cdu, err := rs.ReadCadu()
+					if err != nil {
+						if err != io.EOF {
+							g.Report(fmt.Errorf("%s: %v", c.RemoteAddr(), err))
+						}
+						return
+					}
+					select {
+					case q <- &TimeCadu{Reception: time.Now(), Cadu: cdu}:
+						x.Add(1, 0)
+					case <-ctx.Done():
+						return
+					default:
+						x.Add(0, 1)
+						cdu.Release()
+					}
+				}
+			}(c)
+		}
+	})
+	return q, nil
+}
+
+const (
+	minBackoff = 250 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+func decodeFromTCPClient(ctx context.Context, g *group, addr, codec string) (<-chan *TimeCadu, error) {
+	if _, err := net.ResolveTCPAddr("tcp", addr); err != nil {
+		return nil, err
+	}
+	q := make(chan *TimeCadu, 100)
+	g.Go(func() error {
+		defer close(q)
+
+		var (
+			wait    = minBackoff
+			resumed bool
+			down    time.Time
+			dialer  net.Dialer
+		)
+		for ctx.Err() == nil {
+			c, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if down.IsZero() {
+					down = time.Now()
+				}
+				log.Printf("[reconnect] %s: %s (retry in %s)", addr, err, wait)
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return nil
+				}
+				if wait *= 2; wait > maxBackoff {
+					wait = maxBackoff
+				}
+				continue
+			}
+			if resumed {
+				log.Printf("[reconnect] %s: connected after %s", addr, time.Since(down))
+			}
+			wait, down = minBackoff, time.Time{}
+
+			stop := closeOnDone(ctx, c)
+			raw, flush := Recorder.Stream(c)
+			rs := newReader(unsealed(decompressed(raw, codec), Sealer))
+			for {
+				cdu, err := rs.ReadCadu()
+				if err != nil {
+					if err != io.EOF {
+						g.Report(fmt.Errorf("%s: %v", addr, err))
+					}
+					break
+				}
+				if !send(ctx, q, &TimeCadu{Reception: time.Now(), Cadu: cdu, Resumed: resumed}) {
+					break
+				}
+				resumed = false
+			}
+			stop()
+			flush()
+			c.Close()
+			resumed, down = true, time.Now()
+		}
+		return nil
+	})
+	return q, nil
+}
+
+func decodeFromUDP(ctx context.Context, g *group, addr string) (<-chan *TimeCadu, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var r *net.UDPConn
+	if a.IP.IsMulticast() {
+		var ifi *net.Interface
+		if ifi, err = joinInterface(a); err != nil {
+			return nil, err
+		}
+		r, err = net.ListenMulticastUDP("udp", ifi, a)
+	} else {
+		r, err = net.ListenUDP("udp", a)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if KernelTime {
+		if err := enableTimestamps(r); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	q := make(chan *TimeCadu, 100)
+	g.Go(func() error {
+		return decodeDatagrams(ctx, r, q)
+	})
+	return q, nil
+}
+
+var (
+	JoinIfname string
+	JoinIfaddr string
+)
+
+func joinFlags(set *flag.FlagSet, prefix string) {
+	set.StringVar(&JoinIfname, prefix+"ifname", JoinIfname, "interface joining multicast groups of udp sources")
+	set.StringVar(&JoinIfaddr, prefix+"ifaddr", JoinIfaddr, "address of the interface joining multicast groups of udp sources")
+	set.DurationVar(&RUDPLatency, prefix+"latency", RUDPLatency, "time waited for the datagrams missing from rudp sources before giving up on them")
+}
+
+func joinInterface(a *net.UDPAddr) (*net.Interface, error) {
+	switch {
+	case JoinIfname != "":
+		return net.InterfaceByName(JoinIfname)
+	case JoinIfaddr != "":
+		ip := net.ParseIP(JoinIfaddr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid interface address %s", JoinIfaddr)
+		}
+		return interfaceByAddr(ip)
+	case a.Zone != "":
+		return net.InterfaceByName(a.Zone)
+	default:
+		return nil, nil
+	}
+}
+
+func decodeDatagrams(ctx context.Context, r *net.UDPConn, q chan<- *TimeCadu) error {
+	var (
+		rejected  = make(map[string]int)
+		failed    = make(map[string]int)
+		rs        = newRing(RingSlots)
+		local, _  = r.LocalAddr().(*net.UDPAddr)
+		overruns  int
+		truncated int
+		err       error
+	)
+	defer func() {
+		close(q)
+		r.Close()
+		for a, n := range rejected {
+			log.Printf("[udp] %s: %d datagrams rejected", a, n)
+		}
+		for a, n := range failed {
+			log.Printf("[udp] %s: %d datagrams failed authentication", a, n)
+		}
+		if overruns > 0 {
+			log.Printf("[udp] %s: %d datagrams dropped on a full ring of %d slots", r.LocalAddr(), overruns, RingSlots)
+		}
+		if truncated > 0 {
+			log.Printf("[udp] %s: %d datagrams truncated to %d bytes", r.LocalAddr(), truncated, ringSlotLen)
+		}
+	}()
+	defer closeOnDone(ctx, r)()
+
+	go func() {
+		defer rs.Close()
+		var (
+			scratch = make([]byte, ringSlotLen)
+			oob     = make([]byte, 128)
+		)
+		for {
+			s := rs.Reserve()
+			if s == nil {
+				if _, _, _, _, err = r.ReadMsgUDP(scratch, oob); err != nil {
+					return
+				}
+				overruns++
+				continue
+			}
+			var flags int
+			if s.n, s.oobn, flags, s.from, err = r.ReadMsgUDP(s.buf, s.oob); err != nil {
+				return
+			}
+			if flags&msgTrunc != 0 {
+				truncated++
+			}
+			when, ok := kernelTime(s.oob[:s.oobn])
+			if !ok {
+				when = time.Now()
+			}
+			s.when = when
+			rs.Publish()
+		}
+	}()
+
+	var stopped bool
+	for {
+		s := rs.Next()
+		if s == nil {
+			break
+		}
+		if stopped {
+			rs.Release()
+			continue
+		}
+		from := s.from
+		Recorder.Datagram(s.when, from, local, s.buf[:s.n])
+		if !Allow.Has(from.IP) {
+			sender := from.IP.String()
+			if rejected[sender] == 0 {
+				log.Printf("[udp] %s: sender not allowed, rejecting its datagrams", sender)
+			}
+			rejected[sender]++
+			rs.Release()
+			continue
+		}
+		vs := s.buf[:s.n]
+		if Sealer != nil {
+			var err error
+			if vs, err = unsealDatagram(vs); err != nil {
+				sender := from.IP.String()
+				if failed[sender] == 0 {
+					log.Printf("[udp] %s: %s", sender, err)
+				}
+				failed[sender]++
+				rs.Release()
+				continue
+			}
+		}
+		if !decodeDatagram(ctx, q, vs, s.when, from.String()) {
+			stopped = true
+			r.Close()
+		}
+		rs.Release()
+	}
+	if stopped {
+		return nil
+	}
+	return fmt.Errorf("%s: %v", r.LocalAddr(), err)
+}
+
+func decodeDatagram(ctx context.Context, q chan<- *TimeCadu, vs []byte, when time.Time, sender string) bool {
+	rs := bytes.NewReader(vs)
+	for rs.Len() > 0 {
+		c, err := Profile.Decode(rs)
+		if err != nil {
+			break
+		}
+		if !send(ctx, q, &TimeCadu{Reception: when, Cadu: c, Sender: sender}) {
+			return false
+		}
+	}
+	return true
+}
+
+func openFile(p string) (*os.File, error) {
+	if p == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(p)
+}
+
+func expandPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		if p == "-" {
+			files = append(files, p)
+			continue
+		}
+		i, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !i.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.Walk(p, func(p string, i os.FileInfo, err error) error {
+			if err == nil && i.Mode().IsRegular() {
+				files = append(files, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func decodeFromFile(ctx context.Context, g *group, paths []string, hrdfe bool) (<-chan *TimeCadu, error) {
+	paths, err := expandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	first, offset, err := startAt(paths)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(Profile.Size())
+	if hrdfe {
+		size += 8
+	}
+	offset -= offset % size
+
+	var (
+		rs     []*os.File
+		starts []time.Time
+	)
+	for _, p := range paths[first:] {
+		var when time.Time
+		if NameTime != "" {
+			w, err := timeFromName(NameTime, p)
+			if err != nil {
+				return nil, err
+			}
+			when = w
+		}
+		r, err := openFile(p)
+		if err != nil {
+			for _, r := range rs {
+				r.Close()
+			}
+			return nil, err
+		}
+		rs, starts = append(rs, r), append(starts, when)
+	}
+	if len(rs) > 0 && offset > 0 {
+		if _, err := rs[0].Seek(offset, io.SeekStart); err != nil {
+			for _, r := range rs {
+				r.Close()
+			}
+			return nil, err
+		}
+	}
+	q := make(chan *TimeCadu, 100)
+	track := newProgress(ctx, paths[first:], offset)
+	g.Go(func() error {
+		var (
+			pos     position
+			seeking = StartSequence >= 0
+		)
+		defer func() {
+			track.Done()
+			for _, r := range rs {
+				r.Close()
+			}
+			close(q)
+		}()
+		for i, r := range rs {
+			pos = position{File: r.Name()}
+			if i == 0 {
+				pos.Offset = offset
+			}
+			rs := bufio.NewReader(track.Reader(followFIFO(ctx, r)))
+			for {
+				c, err := readFileCadu(rs, nameTime(starts[i], pos.Offset/size), hrdfe)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("%s: %v", r.Name(), err)
+				}
+				if pos.Offset += size; seeking && c.Sequence != uint32(StartSequence) {
+					continue
+				}
+				seeking = false
+				if c.pos = pos; !send(ctx, q, c) {
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+	return q, nil
+}
+
+func readFileCadu(rs io.Reader, when time.Time, hrdfe bool) (*TimeCadu, error) {
+	if when.IsZero() {
+		when = time.Now()
+	}
+	if hrdfe {
+		var (
+			coarse uint32
+			fine   uint32
+		)
+		binary.Read(rs, binary.LittleEndian, &coarse)
+		binary.Read(rs, binary.LittleEndian, &fine)
+
+		when = time.Unix(int64(coarse), int64(fine)*1000).Add(cadus.Delta)
+	}
+	c, err := Profile.Decode(rs)
+	if err != nil {
+		return nil, err
+	}
+	return &TimeCadu{Reception: when, Cadu: c}, nil
+}
+
+var NameTime string
+
+// NameRate is the nominal downlink bitrate of the files named after their
+// start time: the cadus of a file are received one frame duration apart.
+var NameRate bitrate = DefaultNameRate
+
+const DefaultNameRate = 50e6
+
+// nameTime returns the reception time of the cadu at index in a file starting
+// at start.
+func nameTime(start time.Time, index int64) time.Time {
+	if start.IsZero() || NameRate <= 0 {
+		return start
+	}
+	every := float64(Profile.Length*8) / float64(NameRate)
+	return start.Add(time.Duration(float64(index) * every * float64(time.Second)))
+}
+
+var directives = map[byte][2]string{
+	'Y': {`\d{4}`, "2006"},
+	'y': {`\d{2}`, "06"},
+	'm': {`\d{2}`, "01"},
+	'd': {`\d{2}`, "02"},
+	'j': {`\d{3}`, "002"},
+	'H': {`\d{2}`, "15"},
+	'M': {`\d{2}`, "04"},
+	'S': {`\d{2}`, "05"},
+}
+
+func timeFromName(pattern, file string) (time.Time, error) {
+	if pattern == "rt" {
+		pattern = rtPattern
+	}
+	var expr, layout strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i == len(pattern)-1 {
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			layout.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		if pattern[i] == '%' {
+			expr.WriteString("%")
+			layout.WriteByte('%')
+			continue
+		}
+		d, ok := directives[pattern[i]]
+		if !ok {
+			return time.Time{}, fmt.Errorf("%s: unsupported directive %%%c", pattern, pattern[i])
+		}
+		expr.WriteString(d[0])
+		layout.WriteString(d[1])
+	}
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return time.Time{}, err
+	}
+	name := filepath.Base(file)
+	if n := strings.Count(pattern, "/"); n > 0 {
+		parts := strings.Split(filepath.ToSlash(file), "/")
+		if len(parts) > n+1 {
+			parts = parts[len(parts)-n-1:]
+		}
+		name = strings.Join(parts, "/")
+	}
+	str := re.FindString(name)
+	if str == "" {
+		return time.Time{}, fmt.Errorf("%s: name does not match %s", file, pattern)
+	}
+	return time.ParseInLocation(layout.String(), str, time.UTC)
+}
+
+const (
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+)
+
+var rotatedSuffix = regexp.MustCompile(`\d+$`)
+
+func sortRotated(paths []string) {
+	split := func(p string) (string, int) {
+		ix := rotatedSuffix.FindStringIndex(p)
+		if ix == nil {
+			return p, -1
+		}
+		n, _ := strconv.Atoi(p[ix[0]:])
+		return p[:ix[0]], n
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		pi, ni := split(paths[i])
+		pj, nj := split(paths[j])
+		if pi != pj {
+			return paths[i] < paths[j]
+		}
+		return ni < nj
+	})
+}
+
+// readPCAPHeader returns the byte order, the time unit and the snaplen of a
+// pcap file, the snaplen defaulting to 65535 when the header leaves it unset.
+func readPCAPHeader(r io.Reader) (binary.ByteOrder, time.Duration, uint32, error) {
+	bs := make([]byte, pcapHeaderLen)
+	if _, err := io.ReadFull(r, bs); err != nil {
+		return nil, 0, 0, err
+	}
+	for _, e := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var unit time.Duration
+		switch e.Uint32(bs) {
+		case pcapMagicMicro:
+			unit = time.Microsecond
+		case pcapMagicNano:
+			unit = time.Nanosecond
+		default:
+			continue
+		}
+		snaplen := e.Uint32(bs[16:])
+		if snaplen == 0 {
+			snaplen = recordSnapLen
+		}
+		return e, unit, snaplen, nil
+	}
+	return nil, 0, 0, fmt.Errorf("invalid pcap magic %x", bs[:4])
+}
+
+func decodeFromPCAP(ctx context.Context, g *group, paths []string, cutLen int) (<-chan *TimeCadu, error) {
+	paths, err := expandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	sortRotated(paths)
+	q := make(chan *TimeCadu, 100)
+	track := newProgress(ctx, paths, 0)
+	g.Go(func() error {
+		defer func() {
+			track.Done()
+			close(q)
+		}()
+		for _, p := range paths {
+			if ctx.Err() != nil {
+				return nil
+			}
+			r, err := openFile(p)
+			if err != nil {
+				g.Report(err)
+				continue
+			}
+			rs := bufio.NewReader(track.Reader(r))
+			order, unit, snaplen, err := readPCAPHeader(rs)
+			if err != nil {
+				g.Report(fmt.Errorf("%s: %v", p, err))
+				r.Close()
+				continue
+			}
+			var (
+				offset  = int64(pcapHeaderLen)
+				head    = make([]byte, pktHeaderLen)
+				snapped int
+			)
+			for {
+				if _, err := io.ReadFull(rs, head); err != nil {
+					if err != io.EOF {
+						g.Report(fmt.Errorf("%s: truncated record header at offset %d", p, offset))
+					}
+					break
+				}
+				var (
+					sec    = order.Uint32(head)
+					frac   = order.Uint32(head[4:])
+					length = order.Uint32(head[8:])
+					orig   = order.Uint32(head[12:])
+				)
+				offset += pktHeaderLen
+				if orig > length {
+					snapped++
+				}
+				if length > snaplen {
+					g.Report(fmt.Errorf("%s: record of %d bytes at offset %d larger than the snaplen (%d bytes)", p, length, offset, snaplen))
+					break
+				}
+				bs := make([]byte, length)
+				if _, err := io.ReadFull(rs, bs); err != nil {
+					g.Report(fmt.Errorf("%s: truncated record of %d bytes at offset %d", p, length, offset))
+					break
+				}
+				offset += int64(length)
+				off := blockLen + cutLen
+				if len(bs) < off+Profile.Size() {
+					continue
+				}
+				c, err := Profile.Decode(bytes.NewReader(bs[off:]))
+				if err != nil {
+					continue
+				}
+				when := time.Unix(int64(sec), 0).Add(time.Duration(frac) * unit).UTC()
+				if !send(ctx, q, &TimeCadu{Reception: when, Cadu: c}) {
+					break
+				}
+			}
+			if snapped > 0 {
+				log.Printf("[pcap] %s: %d records truncated by the capture snaplen", p, snapped)
+			}
+			r.Close()
+		}
+		return nil
+	})
+	return q, nil
+}
+
+func openSource(ctx context.Context, proto string, args []string, hrdfe bool) (<-chan *TimeCadu, <-chan error, error) {
+	if err := Profile.Validate(); err != nil {
+		return nil, nil, err
+	}
+	aead, err := loadKey(KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	Sealer = aead
+	if err := loadHooks(ctx); err != nil {
+		return nil, nil, err
+	}
+	if RecordFile != "" && Recorder == nil {
+		if Recorder, err = createRecorder(ctx, RecordFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var uris []string
+	for _, a := range args {
+		if strings.Contains(a, "://") {
+			uris = append(uris, a)
+		}
+	}
+	var (
+		queue <-chan *TimeCadu
+		g     = newGroup(ctx)
+	)
+	switch {
+	case WatchDir != "":
+		queue, err = decodeFromWatch(ctx, g, WatchDir, hrdfe)
+	case len(uris) == 0:
+		queue, err = openInput(ctx, g, proto, args, hrdfe)
+	case len(uris) == len(args):
+		queue, err = openInputs(ctx, g, uris, hrdfe)
+	default:
+		err = fmt.Errorf("inputs should all be given as urls")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	sdNotify("READY=1")
+	return watchdog(ctx, withHooks(ctx, queue)), g.Errors(), nil
+}
+
+func openInput(ctx context.Context, g *group, proto string, args []string, hrdfe bool) (<-chan *TimeCadu, error) {
+	var addr string
+	if len(args) > 0 {
+		addr = args[0]
+	}
+	switch proto {
+	case "udp":
+		return decodeFromUDP(ctx, g, addr)
+	case "rudp":
+		return decodeFromRUDP(ctx, g, addr)
+	case "tcp", "tcp" + deflateSuffix, "tcp" + zstdSuffix:
+		_, codec, _ := compressedScheme(proto)
+		return decodeFromTCP(ctx, g, addr, codec)
+	case "tcp+dial", "tcp+dial" + deflateSuffix, "tcp+dial" + zstdSuffix:
+		_, codec, _ := compressedScheme(proto)
+		return decodeFromTCPClient(ctx, g, addr, codec)
+	case "pcap+udp":
+		return decodeFromPCAP(ctx, g, args, udpHeaderLen)
+	case "pcap+tcp":
+		return decodeFromPCAP(ctx, g, args, tcpHeaderLen)
+	case "file", "":
+		return decodeFromFile(ctx, g, args, hrdfe)
+	case "file+hrdfe":
+		return decodeFromFile(ctx, g, args, true)
+	default:
+		return nil, fmt.Errorf("unsupported protocol %s", proto)
+	}
+}
+
+const mergeWindow = 250 * time.Millisecond
+
+func openInputs(ctx context.Context, g *group, uris []string, hrdfe bool) (<-chan *TimeCadu, error) {
+	qs := make([]<-chan *TimeCadu, 0, len(uris))
+	for _, str := range uris {
+		u, err := url.Parse(str)
+		if err != nil {
+			return nil, err
+		}
+		addr := u.Host
+		if strings.HasPrefix(u.Scheme, "file") || strings.HasPrefix(u.Scheme, "pcap") {
+			addr += u.Path
+		}
+		q, err := openInput(ctx, g, u.Scheme, []string{addr}, hrdfe)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", str, err)
+		}
+		qs = append(qs, tagSource(ctx, q, str))
+	}
+	if len(qs) == 1 {
+		return qs[0], nil
+	}
+	return mergeInputs(ctx, qs, mergeWindow), nil
+}
+
+func tagSource(ctx context.Context, queue <-chan *TimeCadu, source string) <-chan *TimeCadu {
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+		for c := range queue {
+			c.Source = source
+			if !send(ctx, q, c) {
+				return
+			}
+		}
+	}()
+	return q
+}
+
+type input struct {
+	index int
+	cadu  *TimeCadu
+}
+
+func mergeInputs(ctx context.Context, qs []<-chan *TimeCadu, window time.Duration) <-chan *TimeCadu {
+	items := make(chan input, 100*len(qs))
+	for i, q := range qs {
+		go func(i int, q <-chan *TimeCadu) {
+			for c := range q {
+				select {
+				case items <- input{index: i, cadu: c}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case items <- input{index: i}:
+			case <-ctx.Done():
+			}
+		}(i, q)
+	}
+
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+
+		var (
+			pending = make([][]*TimeCadu, len(qs))
+			closed  = make([]bool, len(qs))
+			open    = len(qs)
+			tick    = time.NewTicker(window)
+		)
+		defer tick.Stop()
+
+		next := func(all bool) bool {
+			ix := -1
+			for i, ps := range pending {
+				if len(ps) == 0 {
+					if !closed[i] && !all {
+						return false
+					}
+					continue
+				}
+				if ix < 0 || ps[0].Reception.Before(pending[ix][0].Reception) {
+					ix = i
+				}
+			}
+			if ix < 0 || !send(ctx, q, pending[ix][0]) {
+				return false
+			}
+			pending[ix] = pending[ix][1:]
+			return true
+		}
+		for open > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case i := <-items:
+				if i.cadu == nil {
+					closed[i.index] = true
+					open--
+				} else {
+					pending[i.index] = append(pending[i.index], i.cadu)
+				}
+				for next(false) {
+				}
+			case <-tick.C:
+				for next(true) {
+				}
+			}
+		}
+		for next(true) {
+		}
+	}()
+	return q
+}
+
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case <-sig:
+			sdNotify("STOPPING=1")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+type channels []int
+
+func (c *channels) String() string {
+	vs := make([]string, len(*c))
+	for i, v := range *c {
+		vs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(vs, ",")
+}
+
+func (c *channels) Set(str string) error {
+	var vs []int
+	for _, s := range strings.Split(str, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if v >= 0 {
+			vs = append(vs, v)
+		}
+	}
+	*c = vs
+	return nil
+}
+
+func (c channels) Has(v uint8) bool {
+	for _, x := range c {
+		if x == int(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type allowList []*net.IPNet
+
+var Allow allowList
+
+func (a *allowList) String() string {
+	vs := make([]string, len(*a))
+	for i, n := range *a {
+		vs[i] = n.String()
+	}
+	return strings.Join(vs, ",")
+}
+
+func (a *allowList) Set(str string) error {
+	for _, s := range strings.Split(str, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return fmt.Errorf("%s: invalid address", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			*a = append(*a, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return err
+		}
+		*a = append(*a, n)
+	}
+	return nil
+}
+
+func (a allowList) Has(ip net.IP) bool {
+	if len(a) == 0 {
+		return true
+	}
+	for _, n := range a {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type filter struct {
+	Space    int
+	Channels channels
+	Expected channels
+}
+
+func filterFlags(set *flag.FlagSet) *filter {
+	f := filter{Space: -1}
+	set.IntVar(&f.Space, "scid", f.Space, "keep only cadus of spacecraft id")
+	set.Var(&f.Channels, "vcid", "keep only cadus of virtual channel ids (comma separated)")
+	set.Var(&f.Expected, "expect-vcid", "warn about cadus of other virtual channel ids (comma separated)")
+	set.Var(&Allow, "allow", "accept udp datagrams only from addresses or networks (comma separated)")
+	return &f
+}
+
+func (f filter) Filter(ctx context.Context, queue <-chan *TimeCadu) <-chan *TimeCadu {
+	if f.Space < 0 && len(f.Channels) == 0 && len(f.Expected) == 0 {
+		return queue
+	}
+	q := make(chan *TimeCadu, 100)
+	go func() {
+		defer close(q)
+		unexpected := make(map[uint8]int)
+		defer func() {
+			for vc, n := range unexpected {
+				log.Printf("[filter] vcid %d: %d cadus of an unexpected virtual channel", vc, n)
+			}
+		}()
+		for c := range queue {
+			if f.Space >= 0 && int(c.Space) != f.Space {
+				c.Release()
+				continue
+			}
+			if len(f.Channels) > 0 && !f.Channels.Has(c.Channel) {
+				c.Release()
+				continue
+			}
+			if len(f.Expected) > 0 && !f.Expected.Has(c.Channel) {
+				if unexpected[c.Channel] == 0 {
+					log.Printf("[filter] vcid %d: unexpected virtual channel (sequence %d)", c.Channel, c.Sequence)
+				}
+				unexpected[c.Channel]++
+			}
+			if !send(ctx, q, c) {
+				return
+			}
+		}
+	}()
+	return q
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/busoc/cadus"
+)
+
+func TestDecodeFromTCPClientReport(t *testing.T) {
+	s, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %s", err)
+	}
+	defer s.Close()
+	go func() {
+		c, err := s.Accept()
+		if err != nil {
+			return
+		}
+		// a truncated cadu makes the client report an error
+		c.Write(append(append([]byte(nil), cadus.CaduMagic...), 1, 2, 3))
+		c.Close()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	g := newGroup(ctx)
+	queue, err := decodeFromTCPClient(ctx, g, s.Addr().String(), "")
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %s", err)
+	}
+	errs := g.Errors()
+	select {
+	case err, ok := <-errs:
+		if !ok {
+			t.Fatalf("errors closed while the client is running")
+		}
+		if err == nil {
+			t.Fatalf("nil error reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no error reported for the truncated cadu")
+	}
+	cancel()
+	for range queue {
+	}
+	for range errs {
+	}
+}
+
+func TestTimeFromName(t *testing.T) {
+	data := []struct {
+		Pattern string
+		File    string
+		Want    time.Time
+		Err     bool
+	}{
+		{Pattern: "%Y%m%d_%H%M%S", File: "/data/cadus_20260314_101502.dat", Want: time.Date(2026, 3, 14, 10, 15, 2, 0, time.UTC)},
+		{Pattern: "%Y/%j/%H%M", File: "/archive/2026/073/1015.dat", Want: time.Date(2026, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{Pattern: "%y%m%d%%", File: "260314%.dat", Want: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{Pattern: "%Y%m%d_%H%M%S", File: "cadus.dat", Err: true},
+		{Pattern: "%Y%q", File: "2026.dat", Err: true},
+	}
+	for _, d := range data {
+		t.Run(d.Pattern, func(t *testing.T) {
+			got, err := timeFromName(d.Pattern, d.File)
+			if d.Err {
+				if err == nil {
+					t.Fatalf("%s: time %s parsed with %s", d.File, got, d.Pattern)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !got.Equal(d.Want) {
+				t.Errorf("%s: want %s, got %s", d.File, d.Want, got)
+			}
+		})
+	}
+}
+
+func TestNameTime(t *testing.T) {
+	defer func(r bitrate) { NameRate = r }(NameRate)
+
+	start := time.Date(2026, 3, 14, 10, 15, 2, 0, time.UTC)
+	NameRate = bitrate(Profile.Length * 8 * 1000)
+	for _, d := range []struct {
+		Index int64
+		Want  time.Duration
+	}{{0, 0}, {1, time.Millisecond}, {2500, 2500 * time.Millisecond}} {
+		if got := nameTime(start, d.Index); !got.Equal(start.Add(d.Want)) {
+			t.Errorf("cadu %d: want %s, got %s", d.Index, start.Add(d.Want), got)
+		}
+	}
+	if got := nameTime(time.Time{}, 10); !got.IsZero() {
+		t.Errorf("time given to cadus of a file without start time: %s", got)
+	}
+}
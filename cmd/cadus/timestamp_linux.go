@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func enableTimestamps(c *net.UDPConn) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+func kernelTime(oob []byte) (time.Time, bool) {
+	ms, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, m := range ms {
+		if m.Header.Level != syscall.SOL_SOCKET || m.Header.Type != syscall.SO_TIMESTAMPNS {
+			continue
+		}
+		if len(m.Data) < int(unsafe.Sizeof(syscall.Timespec{})) {
+			continue
+		}
+		ts := (*syscall.Timespec)(unsafe.Pointer(&m.Data[0]))
+		return time.Unix(ts.Unix()).UTC(), true
+	}
+	return time.Time{}, false
+}
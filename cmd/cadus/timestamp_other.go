@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+func enableTimestamps(c *net.UDPConn) error {
+	return fmt.Errorf("kernel timestamps not supported on this platform")
+}
+
+func kernelTime(oob []byte) (time.Time, bool) {
+	return time.Time{}, false
+}
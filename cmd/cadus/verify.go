@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/busoc/cadus"
+)
+
+func runVerify(args []string) {
+	set := flag.NewFlagSet("verify", flag.ExitOnError)
+	proto := set.String("p", "udp", "protocol")
+	hrdfe := set.Bool("hrdfe", false, "skip byte")
+	size := set.Int("dup-window", 1, "number of previous sequences per channel checked for duplicates")
+	quiet := set.Bool("q", false, "suppress per-frame output and exit non-zero when thresholds are exceeded")
+	check := checkFlags(set)
+	logLimit := set.Int("log-limit", DefaultLogLimit, "identical error lines logged per interval before aggregating (0: no limit)")
+	logEvery := set.Duration("log-every", DefaultLogEvery, "interval over which identical error lines are aggregated")
+	set.BoolVar(&KernelTime, "kernel-time", KernelTime, "timestamp udp cadus with kernel receive times")
+	set.StringVar(&NameTime, "time-from-name", NameTime, "strftime pattern of the capture start time in file names, spanning directories when it contains / (e.g. %Y%m%d_%H%M%S, rt: rolling rt archives)")
+	set.Var(&NameRate, "time-from-name-rate", "nominal bitrate of the files, spacing the times of their cadus (e.g. 50Mbps)")
+	profileFlags(set)
+	scanFlags(set)
+	keyFlags(set)
+	fopts := filterFlags(set)
+	joinFlags(set, "")
+	recordFlags(set)
+	hookFlags(set)
+	clientFlags(set)
+	args = parseArgs(set, args)
+
+	if *quiet {
+		rows.SetOutput(ioutil.Discard)
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	st, err := newTracker()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	queue, errc, err := openSource(ctx, *proto, args, *hrdfe)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	failures := watchErrors(errc, nil)
+	queue = fopts.Filter(ctx, queue)
+	if *size > 0 {
+		queue = markDuplicates(ctx, queue, *size, false)
+	}
+	queue = markJumps(ctx, queue)
+	z := verifyCadus(st.Track(ctx, queue), newRateLogger(rows, *logLimit, *logEvery))
+	if err := st.Close(); err != nil {
+		log.Println(err)
+	}
+	closeHooks()
+	if err := Recorder.Close(); err != nil {
+		log.Println(err)
+	}
+	var code int
+	if check.enabled(*quiet) {
+		code = check.Check(z, args)
+	}
+	if <-failures > 0 {
+		code |= ExitSource
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+func verifyCadus(queue <-chan *TimeCadu, errs *rateLogger) Summary {
+	const line = "%-10s | %s | %8d | %8d | %4d bytes | %5d bits | %s"
+
+	var (
+		prevs      = make(map[cadus.ChannelKey]*TimeCadu)
+		sum        Summary
+		verified   int
+		skipped    int
+		damaged    int
+		undetected int
+		nbytes     int
+		nbits      int
+	)
+	want := make([]byte, Profile.BodyLen()-8)
+	for c := range queue {
+		k := c.Key()
+		sum.Update(c, c.Missing(prevs[k]))
+		if !c.Duplicate {
+			prevs[k] = c
+		}
+		if binary.BigEndian.Uint32(c.Payload) != cadus.PRBSTag {
+			skipped++
+			continue
+		}
+		verified++
+
+		tag := binary.BigEndian.Uint32(c.Payload[4:])
+		cadus.PRBS23(tag, want)
+
+		var nb, nx int
+		for i, b := range c.Payload[8:] {
+			if x := b ^ want[i]; x != 0 {
+				nb++
+				for ; x != 0; x &= x - 1 {
+					nx++
+				}
+			}
+		}
+		if nb == 0 {
+			continue
+		}
+		damaged++
+		nbytes += nb
+		nbits += nx
+		err, kind := "-", "damaged cadu (undetected)"
+		if c.Error == nil {
+			undetected++
+		} else {
+			err, kind = c.Error.Error(), "damaged cadu ("+errorKind(c.Error)+")"
+		}
+		errs.Printf(kind, line, k, formatTime(c.Reception), c.Sequence, tag, nb, nx, err)
+	}
+	errs.Flush()
+	log.Printf("%d cadus verified (%d skipped): %d damaged (%d undetected by checksum), %d bytes, %d bits", verified, skipped, damaged, undetected, nbytes, nbits)
+	log.Println(sum)
+	return sum
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	WatchDir   string
+	WatchEvery = 5 * time.Second
+)
+
+type watched struct {
+	size   int64
+	mod    time.Time
+	offset int64
+	done   bool
+}
+
+func decodeFromWatch(ctx context.Context, g *group, dir string, hrdfe bool) (<-chan *TimeCadu, error) {
+	if i, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !i.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", dir)
+	}
+	if WatchEvery <= 0 {
+		return nil, fmt.Errorf("%s: watch interval must be positive", dir)
+	}
+	st, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	q := make(chan *TimeCadu, 100)
+	g.Go(func() error {
+		defer close(q)
+
+		files := make(map[string]*watched)
+		for f, n := range st.Inputs {
+			files[f] = &watched{offset: n}
+		}
+		tick := time.NewTicker(WatchEvery)
+		defer tick.Stop()
+		for {
+			ready, err := scanWatched(dir, files)
+			if err != nil {
+				return err
+			}
+			for _, p := range ready {
+				w := files[p]
+				n, err := decodeWatched(ctx, q, p, w, hrdfe)
+				if err != nil {
+					w.done = true
+					g.Report(err)
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+				if n > 0 {
+					log.Printf("[watch] %s: %d cadus", p, n)
+				}
+			}
+			select {
+			case <-tick.C:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return q, nil
+}
+
+// scanWatched returns the files of dir whose size and modification time did
+// not change since the previous scan and that grew since they were last read.
+func scanWatched(dir string, files map[string]*watched) ([]string, error) {
+	paths, err := expandPaths([]string{dir})
+	if err != nil {
+		return nil, err
+	}
+	var ready []string
+	for _, p := range paths {
+		i, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		w, ok := files[p]
+		if !ok {
+			files[p] = &watched{size: i.Size(), mod: i.ModTime()}
+			continue
+		}
+		if w.done {
+			continue
+		}
+		if w.size == i.Size() && w.mod.Equal(i.ModTime()) && i.Size() > w.offset {
+			ready = append(ready, p)
+		}
+		w.size, w.mod = i.Size(), i.ModTime()
+	}
+	sort.Strings(ready)
+	return ready, nil
+}
+
+// decodeWatched reads the cadus of file from the offset where the previous scan
+// stopped. A truncated record at the end is left for the next scan since the
+// recorder can still append to the file.
+func decodeWatched(ctx context.Context, q chan<- *TimeCadu, file string, w *watched, hrdfe bool) (int, error) {
+	var when time.Time
+	if NameTime != "" {
+		w, err := timeFromName(NameTime, file)
+		if err != nil {
+			return 0, err
+		}
+		when = w
+	}
+	r, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	if _, err := r.Seek(w.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var (
+		read  int64
+		rs    = bufio.NewReader(&countReader{Reader: r, count: &read})
+		count int
+		base  = w.offset
+		size  = int64(Profile.Size())
+	)
+	if hrdfe {
+		size += 8
+	}
+	for {
+		c, err := readFileCadu(rs, nameTime(when, w.offset/size), hrdfe)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("%s: %v", file, err)
+		}
+		w.offset = base + read - int64(rs.Buffered())
+		c.pos = position{File: file, Offset: w.offset}
+		if !send(ctx, q, c) {
+			return count, nil
+		}
+		count++
+	}
+}
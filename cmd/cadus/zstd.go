@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// zstd streams are written as a single frame of raw and rle blocks: the runs
+// of a byte, like the idle fill of the cadus, are reduced to one byte and the
+// rest is carried as is. zstd itself reads them back. The reader accepts the
+// same blocks and rejects the compressed ones, it is not a full decoder.
+const (
+	zstdMagic     = 0xFD2FB528
+	zstdSkippable = 0x184D2A50
+	zstdBlockMax  = 128 << 10
+	zstdWindow    = 0x38 // 128KB window, the size of the largest block
+	zstdRunMin    = 32
+)
+
+const (
+	zstdRaw = iota
+	zstdRLE
+	zstdCompressed
+)
+
+type zstdWriter struct {
+	w       io.Writer
+	buf     []byte
+	out     []byte
+	started bool
+}
+
+func newZstdWriter(w io.Writer) *zstdWriter {
+	return &zstdWriter{w: w}
+}
+
+func (z *zstdWriter) Write(bs []byte) (int, error) {
+	z.buf = append(z.buf, bs...)
+	if len(z.buf) >= zstdBlockMax {
+		return len(bs), z.Flush()
+	}
+	return len(bs), nil
+}
+
+// Flush writes the bytes buffered as the next blocks of the frame.
+func (z *zstdWriter) Flush() error {
+	if len(z.buf) == 0 && z.started {
+		return nil
+	}
+	return z.write(false)
+}
+
+// Close writes the bytes buffered and ends the frame with an empty last block.
+func (z *zstdWriter) Close() error {
+	return z.write(true)
+}
+
+func (z *zstdWriter) write(last bool) error {
+	out := z.out[:0]
+	if !z.started {
+		out = append(out, 0, 0, 0, 0, 0, zstdWindow)
+		binary.LittleEndian.PutUint32(out, zstdMagic)
+		z.started = true
+	}
+	for bs := z.buf; len(bs) > 0; {
+		x, n := nextRun(bs)
+		for raw := bs[:x]; len(raw) > 0; {
+			k := len(raw)
+			if k > zstdBlockMax {
+				k = zstdBlockMax
+			}
+			out = append(zstdBlock(out, zstdRaw, k, false), raw[:k]...)
+			raw = raw[k:]
+		}
+		for r := n; r > 0; {
+			k := r
+			if k > zstdBlockMax {
+				k = zstdBlockMax
+			}
+			out = append(zstdBlock(out, zstdRLE, k, false), bs[x])
+			r -= k
+		}
+		bs = bs[x+n:]
+	}
+	if last {
+		out = zstdBlock(out, zstdRaw, 0, true)
+	}
+	z.buf, z.out = z.buf[:0], out
+	_, err := z.w.Write(out)
+	return err
+}
+
+func zstdBlock(out []byte, kind, size int, last bool) []byte {
+	h := uint32(size)<<3 | uint32(kind)<<1
+	if last {
+		h |= 1
+	}
+	return append(out, byte(h), byte(h>>8), byte(h>>16))
+}
+
+// nextRun returns the offset and the length of the first run of at least
+// zstdRunMin identical bytes in bs, or the length of bs when there is none.
+func nextRun(bs []byte) (int, int) {
+	for i := 0; i < len(bs); {
+		j := i + 1
+		for j < len(bs) && bs[j] == bs[i] {
+			j++
+		}
+		if j-i >= zstdRunMin {
+			return i, j - i
+		}
+		i = j
+	}
+	return len(bs), 0
+}
+
+type zstdReader struct {
+	r        *bufio.Reader
+	buf      []byte
+	block    []byte
+	framed   bool
+	checksum bool
+	err      error
+}
+
+func newZstdReader(r io.Reader) *zstdReader {
+	return &zstdReader{r: bufio.NewReader(r)}
+}
+
+func (z *zstdReader) Read(bs []byte) (int, error) {
+	for len(z.block) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		if z.framed {
+			z.err = z.readBlock()
+		} else {
+			z.err = z.readHeader()
+		}
+	}
+	n := copy(bs, z.block)
+	z.block = z.block[n:]
+	return n, nil
+}
+
+func (z *zstdReader) readHeader() error {
+	var buf [4]byte
+	if _, err := io.ReadFull(z.r, buf[:]); err != nil {
+		return err
+	}
+	magic := binary.LittleEndian.Uint32(buf[:])
+	if magic&0xFFFFFFF0 == zstdSkippable {
+		if _, err := io.ReadFull(z.r, buf[:]); err != nil {
+			return noEOF(err)
+		}
+		_, err := io.CopyN(ioutil.Discard, z.r, int64(binary.LittleEndian.Uint32(buf[:])))
+		return noEOF(err)
+	}
+	if magic != zstdMagic {
+		return fmt.Errorf("zstd: invalid magic number %08x", magic)
+	}
+	d, err := z.r.ReadByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	if d&0x08 != 0 {
+		return fmt.Errorf("zstd: reserved bit set in frame header")
+	}
+	var (
+		single = d&0x20 != 0
+		size   = [4]int{0, 2, 4, 8}[d>>6] + [4]int{0, 1, 2, 4}[d&0x03]
+	)
+	if !single {
+		size++
+	} else if d>>6 == 0 {
+		size++
+	}
+	if _, err := io.CopyN(ioutil.Discard, z.r, int64(size)); err != nil {
+		return noEOF(err)
+	}
+	z.framed, z.checksum = true, d&0x04 != 0
+	return nil
+}
+
+func (z *zstdReader) readBlock() error {
+	var buf [3]byte
+	if _, err := io.ReadFull(z.r, buf[:]); err != nil {
+		return noEOF(err)
+	}
+	var (
+		h    = uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16
+		last = h&1 != 0
+		size = int(h >> 3)
+	)
+	if size > zstdBlockMax {
+		return fmt.Errorf("zstd: block of %d bytes larger than %d bytes", size, zstdBlockMax)
+	}
+	if cap(z.buf) < size {
+		z.buf = make([]byte, size)
+	}
+	vs := z.buf[:size]
+	switch kind := (h >> 1) & 0x03; kind {
+	case zstdRaw:
+		if _, err := io.ReadFull(z.r, vs); err != nil {
+			return noEOF(err)
+		}
+	case zstdRLE:
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return noEOF(err)
+		}
+		for i := range vs {
+			vs[i] = b
+		}
+	case zstdCompressed:
+		return fmt.Errorf("zstd: compressed blocks not supported (raw and rle blocks only)")
+	default:
+		return fmt.Errorf("zstd: reserved block type")
+	}
+	z.block = vs
+	if last {
+		z.framed = false
+		if z.checksum {
+			if _, err := io.CopyN(ioutil.Discard, z.r, 4); err != nil {
+				return noEOF(err)
+			}
+		}
+	}
+	return nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,249 @@
+package cadus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	HRDLWord  = []byte{0xf8, 0x2e, 0x35, 0x53}
+	HRDLStuff = []byte{0xf8, 0x2e, 0x35, 0xaa}
+)
+
+var (
+	GPS   = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+	UNIX  = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	Delta = GPS.Sub(UNIX)
+)
+
+var IdlePayload = bytes.Repeat([]byte{0x55}, CaduBodyLen)
+
+var (
+	ErrSyncword = errors.New("missing syncword")
+	ErrMultiple = errors.New("multiple syncword")
+)
+
+func ReadTime6(coarse uint32, fine uint16) time.Time {
+	t := time.Unix(int64(coarse), 0).UTC()
+
+	fs := float64(fine) / 65536.0 * 1000.0
+	ms := time.Duration(fs) * time.Millisecond
+	return t.Add(ms).UTC()
+}
+
+type HRDLReader struct {
+	Profile  Profile
+	Limit    int
+	Dropped  int
+	Tolerant bool
+
+	inner   *bufio.Reader
+	buffer  []byte
+	scanned int
+	skip    int
+	when    time.Time
+}
+
+func NewHRDLReader(r io.Reader, hrdfe bool) *HRDLReader {
+	rs := &HRDLReader{Profile: DefaultProfile, inner: bufio.NewReaderSize(r, 1<<20)}
+	if hrdfe {
+		rs.skip = 8
+	}
+	return rs
+}
+
+func (r *HRDLReader) Read(bs []byte) (n int, err error) {
+	if len(bs) == 0 {
+		return 0, nil
+	}
+	if r.Tolerant {
+		defer func() {
+			if v := recover(); v != nil {
+				r.buffer, r.scanned = r.buffer[:0], 0
+				n, err = 0, CorruptedError{Reason: v}
+			}
+		}()
+	}
+	for {
+		if n, ok := r.copyHRDL(bs); ok {
+			return n, nil
+		}
+		if err := r.readCadu(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// copyHRDL copies to bs the first frame of the buffer once the sync word of
+// the next one has been received. Sync words are searched in the stuffed
+// stream, where they can only start a frame, and the frame is destuffed once
+// found.
+func (r *HRDLReader) copyHRDL(bs []byte) (int, bool) {
+	if len(r.buffer) < len(HRDLWord) || !bytes.Equal(r.buffer[:len(HRDLWord)], HRDLWord) {
+		ix := bytes.Index(r.buffer, HRDLWord)
+		if ix < 0 {
+			if keep := len(HRDLWord) - 1; len(r.buffer) > keep {
+				r.buffer = append(r.buffer[:0], r.buffer[len(r.buffer)-keep:]...)
+			}
+			r.scanned = 0
+			return 0, false
+		}
+		r.buffer, r.scanned = append(r.buffer[:0], r.buffer[ix:]...), 0
+	}
+	from := len(HRDLWord)
+	if r.scanned > from+len(HRDLWord) {
+		from = r.scanned - len(HRDLWord)
+	}
+	ix := bytes.Index(r.buffer[from:], HRDLWord)
+	if ix < 0 {
+		r.scanned = len(r.buffer)
+		if r.Limit > 0 && len(r.buffer) > r.Limit {
+			r.Dropped += len(r.buffer)
+			r.buffer, r.scanned = r.buffer[:0], 0
+		}
+		return 0, false
+	}
+	z := from + ix
+	frame := bytes.Replace(r.buffer[:z], HRDLStuff, HRDLWord[:3], -1)
+
+	s := len(frame)
+	if len(frame) >= 8 {
+		if n := int64(binary.LittleEndian.Uint32(frame[len(HRDLWord):])) + 12; n < int64(s) {
+			s = int(n)
+		}
+	}
+	n := copy(bs, frame[:s])
+	r.buffer, r.scanned = append(r.buffer[:0], r.buffer[z:]...), 0
+	return n, true
+}
+
+func (r *HRDLReader) Reception() time.Time {
+	return r.when
+}
+
+// readCadu appends to the buffer the payload of the next cadu, decoded with
+// the profile of r whatever its checksum.
+func (r *HRDLReader) readCadu() error {
+	if r.skip > 0 {
+		vs := make([]byte, r.skip)
+		if _, err := io.ReadFull(r.inner, vs); err != nil {
+			return err
+		}
+		if r.skip >= 8 {
+			coarse, fine := binary.LittleEndian.Uint32(vs), binary.LittleEndian.Uint32(vs[4:])
+			r.when = time.Unix(int64(coarse), int64(fine)*1000).Add(Delta)
+		}
+	}
+	p := r.Profile
+	if p.Length == 0 {
+		p = DefaultProfile
+	}
+	c, err := p.Decode(r.inner)
+	if err != nil {
+		if err == io.EOF && r.skip > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	r.buffer = append(r.buffer, c.Payload...)
+	c.Release()
+	return nil
+}
+
+type Segmenter interface {
+	Segment() ([]byte, uint16, error)
+}
+
+type Encapsulator struct {
+	Limit int
+	// Size is the length of the segments, CaduBodyLen when zero.
+	Size int
+
+	inner  io.Reader
+	buffer []byte
+	starts []int
+	done   bool
+}
+
+func NewEncapsulator(r io.Reader) *Encapsulator {
+	return &Encapsulator{inner: bufio.NewReaderSize(r, 1<<20)}
+}
+
+func (e *Encapsulator) Read(bs []byte) (int, error) {
+	vs, _, err := e.Segment()
+	if err != nil {
+		return 0, err
+	}
+	return copy(bs, vs), nil
+}
+
+func (e *Encapsulator) Segment() ([]byte, uint16, error) {
+	size := e.Size
+	if size <= 0 {
+		size = CaduBodyLen
+	}
+	for !e.done && len(e.buffer) < size {
+		switch err := e.next(); err {
+		case nil:
+		case io.EOF:
+			e.done = true
+		default:
+			return nil, 0, err
+		}
+	}
+	if len(e.buffer) == 0 {
+		return nil, 0, io.EOF
+	}
+	vs := make([]byte, size)
+	for i := copy(vs, e.buffer); i < len(vs); i++ {
+		vs[i] = IdlePayload[0]
+	}
+	pointer := uint16(PointerNoStart)
+	if len(e.starts) > 0 && e.starts[0] < size {
+		pointer = uint16(e.starts[0])
+	}
+	if len(e.buffer) > size {
+		e.buffer = e.buffer[size:]
+	} else {
+		e.buffer = e.buffer[:0]
+	}
+	ss := e.starts[:0]
+	for _, s := range e.starts {
+		if s -= size; s >= 0 {
+			ss = append(ss, s)
+		}
+	}
+	e.starts = ss
+	return vs, pointer, nil
+}
+
+func (e *Encapsulator) next() error {
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(e.inner, head); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+	if !bytes.Equal(head[:len(HRDLWord)], HRDLWord) {
+		return fmt.Errorf("invalid sync word found %x", head[:len(HRDLWord)])
+	}
+	size := int64(binary.LittleEndian.Uint32(head[len(HRDLWord):])) + 4
+	if e.Limit > 0 && size > int64(e.Limit) {
+		return fmt.Errorf("frame too long (%d bytes)", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(e.inner, body); err != nil {
+		return err
+	}
+	e.starts = append(e.starts, len(e.buffer))
+	e.buffer = append(e.buffer, head[:len(HRDLWord)]...)
+	e.buffer = append(e.buffer, bytes.Replace(head[len(HRDLWord):], HRDLWord[:3], HRDLStuff, -1)...)
+	e.buffer = append(e.buffer, bytes.Replace(body, HRDLWord[:3], HRDLStuff, -1)...)
+	return nil
+}
@@ -0,0 +1,117 @@
+package cadus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func hrdlFrame(body []byte) []byte {
+	bs := make([]byte, 8, len(body)+12)
+	copy(bs, HRDLWord)
+	binary.LittleEndian.PutUint32(bs[len(HRDLWord):], uint32(len(body)))
+	bs = append(bs, body...)
+	return append(bs, 0, 0, 0, 0)
+}
+
+// encapsulate stuffs and splits frames into the cadus a HRDLReader expects.
+func encapsulate(t testing.TB, frames ...[]byte) []byte {
+	e := NewEncapsulator(bytes.NewReader(bytes.Join(frames, nil)))
+	var (
+		buf bytes.Buffer
+		seq uint32
+	)
+	for {
+		vs, pointer, err := e.Segment()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("encapsulating frames: %v", err)
+		}
+		c := Cadu{
+			Header:  &Header{Word: binary.BigEndian.Uint32(CaduMagic), Sequence: seq, Data: pointer},
+			Payload: vs,
+		}
+		buf.Write(DefaultProfile.Encode(&c))
+		seq++
+	}
+	return buf.Bytes()
+}
+
+func readFrames(r *HRDLReader, n int) ([][]byte, error) {
+	var fs [][]byte
+	for i := 0; i < n; i++ {
+		bs := make([]byte, 1<<20)
+		z, err := r.Read(bs)
+		if err != nil {
+			return fs, err
+		}
+		fs = append(fs, bs[:z])
+	}
+	return fs, nil
+}
+
+func TestHRDLReader(t *testing.T) {
+	data := []struct {
+		Name string
+		Body []byte
+	}{
+		{Name: "short", Body: []byte("hrdl")},
+		{Name: "one cadu", Body: bytes.Repeat([]byte{0xa5}, CaduBodyLen-12)},
+		{Name: "several cadus", Body: bytes.Repeat([]byte{0x01, 0x02, 0x03}, 2000)},
+		{Name: "stuffed", Body: bytes.Repeat(HRDLWord, 600)},
+		{Name: "stuff pattern", Body: bytes.Repeat(HRDLStuff, 600)},
+	}
+	for _, d := range data {
+		want := hrdlFrame(d.Body)
+		rs := NewHRDLReader(bytes.NewReader(encapsulate(t, want, hrdlFrame(nil))), false)
+		fs, err := readFrames(rs, 1)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", d.Name, err)
+			continue
+		}
+		if !bytes.Equal(fs[0], want) {
+			t.Errorf("%s: frames mismatched (%d bytes read, want %d)", d.Name, len(fs[0]), len(want))
+		}
+	}
+}
+
+func FuzzDestuff(f *testing.F) {
+	f.Add([]byte("hrdl"))
+	f.Add(bytes.Repeat(HRDLWord[:3], 400))
+	f.Add(bytes.Repeat(HRDLStuff, 400))
+	f.Add(append(bytes.Repeat([]byte{0}, CaduBodyLen-13), HRDLWord...))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		want := hrdlFrame(body)
+		rs := NewHRDLReader(bytes.NewReader(encapsulate(t, want, hrdlFrame(nil))), false)
+		fs, err := readFrames(rs, 1)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !bytes.Equal(fs[0], want) {
+			t.Fatalf("frames mismatched (%d bytes read, want %d)", len(fs[0]), len(want))
+		}
+	})
+}
+
+func FuzzHRDLReader(f *testing.F) {
+	f.Add(encapsulate(f, hrdlFrame([]byte("hrdl")), hrdlFrame(nil)), false)
+	f.Add(encapsulate(f, hrdlFrame(bytes.Repeat(HRDLWord, 300)), hrdlFrame(nil)), true)
+	f.Add(bytes.Repeat(append(make([]byte, CaduHeaderLen), HRDLWord...), 300), false)
+	f.Fuzz(func(t *testing.T, data []byte, hrdfe bool) {
+		rs := NewHRDLReader(bytes.NewReader(data), hrdfe)
+		rs.Limit = 1 << 16
+		bs := make([]byte, 1<<16)
+		for i := 0; i < 1000; i++ {
+			n, err := rs.Read(bs)
+			if err != nil {
+				return
+			}
+			if n > len(bs) {
+				t.Fatalf("%d bytes read in a %d bytes buffer", n, len(bs))
+			}
+		}
+	})
+}
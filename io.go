@@ -0,0 +1,208 @@
+package cadus
+
+import (
+	"bufio"
+	"io"
+)
+
+type Option func(*Profile)
+
+func WithProfile(p Profile) Option {
+	return func(o *Profile) {
+		*o = p
+	}
+}
+
+// WithFrame sets the type of the frames decoded. TM frames having no fhec,
+// the field and its check are removed from the profile.
+func WithFrame(frame string) Option {
+	return func(p *Profile) {
+		p.Frame = frame
+		if frame == FrameTM {
+			p.FHEC, p.Check = false, false
+		}
+	}
+}
+
+func WithLength(n int) Option {
+	return func(p *Profile) {
+		p.Length = n
+	}
+}
+
+func WithFHEC(present, check bool) Option {
+	return func(p *Profile) {
+		p.FHEC, p.Check = present, check
+	}
+}
+
+func WithFECF(present bool) Option {
+	return func(p *Profile) {
+		p.FECF = present
+	}
+}
+
+func WithOCF(present bool) Option {
+	return func(p *Profile) {
+		p.OCF = present
+	}
+}
+
+func WithInsert(n int) Option {
+	return func(p *Profile) {
+		p.Insert = n
+	}
+}
+
+func WithRandomized(randomized bool) Option {
+	return func(p *Profile) {
+		p.Randomized = randomized
+	}
+}
+
+func WithRS(depth int) Option {
+	return func(p *Profile) {
+		p.RS = depth
+	}
+}
+
+func WithTolerant(tolerant bool) Option {
+	return func(p *Profile) {
+		p.Tolerant = tolerant
+	}
+}
+
+func WithSkip(head, tail int) Option {
+	return func(p *Profile) {
+		p.Head, p.Tail = head, tail
+	}
+}
+
+func NewProfile(opts ...Option) (Profile, error) {
+	p := DefaultProfile
+	for _, o := range opts {
+		o(&p)
+	}
+	return p, p.Validate()
+}
+
+type Reader struct {
+	inner   *bufio.Reader
+	profile Profile
+}
+
+func NewReader(r io.Reader, opts ...Option) (*Reader, error) {
+	p, err := NewProfile(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{inner: bufio.NewReaderSize(r, 4096), profile: p}, nil
+}
+
+func (r *Reader) Profile() Profile {
+	return r.profile
+}
+
+func (r *Reader) ReadCadu() (*Cadu, error) {
+	return r.profile.Decode(r.inner)
+}
+
+type Writer struct {
+	inner   io.Writer
+	profile Profile
+}
+
+func NewWriter(w io.Writer, opts ...Option) (*Writer, error) {
+	p, err := NewProfile(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{inner: w, profile: p}, nil
+}
+
+func (w *Writer) Profile() Profile {
+	return w.profile
+}
+
+func (w *Writer) WriteCadu(c *Cadu) error {
+	_, err := w.inner.Write(w.profile.Encode(c))
+	return err
+}
+
+// Decode reads a single frame from r with the default profile modified by
+// opts. Callers decoding a stream should prefer a Reader that validates the
+// profile once and buffers r.
+func Decode(r io.Reader, opts ...Option) (*Cadu, error) {
+	p, err := NewProfile(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return p.Decode(r)
+}
+
+// Encode writes c to w with the default profile modified by opts.
+func Encode(w io.Writer, c *Cadu, opts ...Option) error {
+	p, err := NewProfile(opts...)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(p.Encode(c))
+	return err
+}
+
+// Decoder pulls frames one at a time from a stream, leaving concurrency and
+// backpressure to its caller:
+//
+//	for {
+//		c, err := d.Next()
+//		if err != nil {
+//			break
+//		}
+//		...
+//	}
+//	if err := d.Err(); err != nil {
+//		...
+//	}
+type Decoder struct {
+	inner *Reader
+	err   error
+}
+
+func NewDecoder(r io.Reader, opts ...Option) (*Decoder, error) {
+	rs, err := NewReader(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{inner: rs}, nil
+}
+
+func (d *Decoder) Profile() Profile {
+	return d.inner.Profile()
+}
+
+// Next returns the next frame of the stream, or io.EOF once it is exhausted.
+// Frames with an invalid checksum are returned with their Error set. In
+// tolerant mode, a malformed frame is reported as a CorruptedError and
+// decoding can go on with the next call; any other error ends the stream and
+// is returned again by every later call.
+func (d *Decoder) Next() (*Cadu, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	c, err := d.inner.ReadCadu()
+	if err != nil {
+		if _, ok := err.(CorruptedError); !ok {
+			d.err = err
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// Err returns the error that ended the stream, nil when it ended at io.EOF.
+func (d *Decoder) Err() error {
+	if d.err == io.EOF {
+		return nil
+	}
+	return d.err
+}
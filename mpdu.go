@@ -0,0 +1,275 @@
+package cadus
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	PacketHeaderLen   = 6
+	MaxPacketSequence = 1 << 14
+	IdleAPID          = 0x7FF
+)
+
+const (
+	SpaceVersion = 0
+	EncapVersion = 7
+)
+
+const (
+	ProtocolIdle     = 0
+	ProtocolLTP      = 1
+	ProtocolIPE      = 2
+	ProtocolCFDP     = 3
+	ProtocolExtended = 6
+	ProtocolMission  = 7
+)
+
+var protocols = map[uint8]string{
+	ProtocolIdle:     "idle",
+	ProtocolLTP:      "ltp",
+	ProtocolIPE:      "ipe",
+	ProtocolCFDP:     "cfdp",
+	ProtocolExtended: "extended",
+	ProtocolMission:  "mission",
+}
+
+func ProtocolName(p uint8) string {
+	if n, ok := protocols[p]; ok {
+		return n
+	}
+	return fmt.Sprintf("protocol-%d", p)
+}
+
+type Packet struct {
+	Version   uint8
+	Type      uint8
+	Secondary bool
+	APID      uint16
+	Segment   uint8
+	Sequence  uint16
+	Protocol  uint8
+	Channel   ChannelKey
+	Header    int
+	Data      []byte
+}
+
+func (p *Packet) Len() int {
+	return len(p.Data)
+}
+
+func (p *Packet) Encapsulated() bool {
+	return p.Version == EncapVersion
+}
+
+func (p *Packet) Idle() bool {
+	if p.Encapsulated() {
+		return p.Protocol == ProtocolIdle
+	}
+	return p.APID == IdleAPID
+}
+
+func (p *Packet) Payload() []byte {
+	vs := p.Data[p.Header:]
+	if p.Encapsulated() && p.Protocol == ProtocolIPE {
+		for len(vs) > 0 && vs[0]&1 == 0 {
+			vs = vs[1:]
+		}
+		if len(vs) > 0 {
+			vs = vs[1:]
+		}
+	}
+	return vs
+}
+
+func (p *Packet) CounterMask() uint16 {
+	return MaxPacketSequence - 1
+}
+
+func (p *Packet) Missing(o *Packet) uint16 {
+	if o == nil || p.Encapsulated() {
+		return 0
+	}
+	mask := p.CounterMask()
+	if delta := (p.Sequence - o.Sequence) & mask; delta > 1 && delta <= mask/2 {
+		return delta - 1
+	}
+	return 0
+}
+
+func (p *Packet) String() string {
+	if p.Encapsulated() {
+		return fmt.Sprintf("%s encap %s (%d bytes)", p.Channel, ProtocolName(p.Protocol), len(p.Data))
+	}
+	return fmt.Sprintf("%s apid %d seq %d (%d bytes)", p.Channel, p.APID, p.Sequence, len(p.Data))
+}
+
+func DecodePacket(bs []byte) (*Packet, error) {
+	size, err := packetLen(bs)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 || len(bs) < size {
+		return nil, fmt.Errorf("packet too short (%d bytes, want %d)", len(bs), size)
+	}
+	if bs[0]>>5 == EncapVersion {
+		p := Packet{
+			Version:  EncapVersion,
+			Protocol: (bs[0] >> 2) & 0x07,
+			Header:   encapHeaderLen(bs[0]),
+			Data:     bs[:size],
+		}
+		return &p, nil
+	}
+	id, seq := binary.BigEndian.Uint16(bs), binary.BigEndian.Uint16(bs[2:])
+	p := Packet{
+		Version:   uint8(id >> 13),
+		Type:      uint8(id>>12) & 1,
+		Secondary: (id>>11)&1 == 1,
+		APID:      id & 0x7FF,
+		Segment:   uint8(seq >> 14),
+		Sequence:  seq & 0x3FFF,
+		Header:    PacketHeaderLen,
+		Data:      bs[:size],
+	}
+	return &p, nil
+}
+
+func encapHeaderLen(b byte) int {
+	return [4]int{1, 2, 4, 8}[b&0x03]
+}
+
+func packetLen(bs []byte) (int, error) {
+	if len(bs) == 0 {
+		return -1, nil
+	}
+	switch v := bs[0] >> 5; v {
+	case SpaceVersion:
+		if len(bs) < PacketHeaderLen {
+			return -1, nil
+		}
+		return int(binary.BigEndian.Uint16(bs[4:])) + PacketHeaderLen + 1, nil
+	case EncapVersion:
+		z := encapHeaderLen(bs[0])
+		if len(bs) < z {
+			return -1, nil
+		}
+		var n int
+		switch z {
+		case 1:
+			n = 1
+		case 2:
+			n = int(bs[1])
+		case 4:
+			n = int(binary.BigEndian.Uint16(bs[2:]))
+		case 8:
+			n = int(binary.BigEndian.Uint32(bs[4:]))
+		}
+		if n < z {
+			return 0, fmt.Errorf("invalid encapsulation packet length %d", n)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("unsupported packet version %d", v)
+	}
+}
+
+// channelState keeps the sequence of the last cadu of a channel rather than
+// the cadu itself that may be released once extracted.
+type channelState struct {
+	sequence uint32
+	started  bool
+	buffer   []byte
+	synced   bool
+}
+
+type Extractor struct {
+	channels map[ChannelKey]*channelState
+	Dropped  int
+	Limit    int
+}
+
+func NewExtractor() *Extractor {
+	return &Extractor{channels: make(map[ChannelKey]*channelState)}
+}
+
+func (e *Extractor) Extract(c *Cadu) []*Packet {
+	k := c.Key()
+	s, ok := e.channels[k]
+	if !ok {
+		s = &channelState{}
+		e.channels[k] = s
+	}
+	if c.Error != nil {
+		e.reset(s)
+		return nil
+	}
+	if s.started && c.Missing(&Cadu{Header: &Header{Sequence: s.sequence}}) > 0 {
+		e.reset(s)
+	}
+	s.sequence, s.started = c.Sequence, true
+
+	switch ptr := int(c.Pointer()); {
+	case ptr == PointerIdle:
+		return nil
+	case ptr == PointerNoStart:
+		if !s.synced {
+			return nil
+		}
+		s.buffer = append(s.buffer, c.Payload...)
+	case ptr >= len(c.Payload):
+		e.reset(s)
+		return nil
+	default:
+		if s.synced {
+			s.buffer = append(s.buffer, c.Payload[:ptr]...)
+			if n := len(s.buffer); n > 0 {
+				if z, err := packetLen(s.buffer); err != nil || z != n {
+					e.reset(s)
+				}
+			}
+		}
+		if s.synced {
+			s.buffer = append(s.buffer, c.Payload[ptr:]...)
+		} else {
+			s.buffer, s.synced = append(s.buffer[:0], c.Payload[ptr:]...), true
+		}
+	}
+	ps := e.split(k, s)
+	if e.Limit > 0 && len(s.buffer) > e.Limit {
+		e.reset(s)
+	}
+	return ps
+}
+
+func (e *Extractor) split(k ChannelKey, s *channelState) []*Packet {
+	var ps []*Packet
+	for len(s.buffer) > 0 {
+		n, err := packetLen(s.buffer)
+		if err != nil {
+			e.reset(s)
+			break
+		}
+		if n < 0 || len(s.buffer) < n {
+			break
+		}
+		vs := make([]byte, n)
+		copy(vs, s.buffer)
+		p, err := DecodePacket(vs)
+		if err != nil {
+			e.reset(s)
+			break
+		}
+		p.Channel = k
+		ps = append(ps, p)
+		s.buffer = s.buffer[n:]
+	}
+	return ps
+}
+
+func (e *Extractor) reset(s *channelState) {
+	if len(s.buffer) > 0 {
+		e.Dropped++
+	}
+	s.buffer, s.synced = s.buffer[:0], false
+}
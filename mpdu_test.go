@@ -0,0 +1,59 @@
+package cadus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPacketMissing(t *testing.T) {
+	data := []struct {
+		Name string
+		Prev uint16
+		Curr uint16
+		Want uint16
+	}{
+		{Name: "next", Prev: 10, Curr: 11, Want: 0},
+		{Name: "gap", Prev: 10, Curr: 14, Want: 3},
+		{Name: "wrap", Prev: MaxPacketSequence - 1, Curr: 0, Want: 0},
+		{Name: "wrap+gap", Prev: MaxPacketSequence - 2, Curr: 2, Want: 3},
+		{Name: "duplicate", Prev: 10, Curr: 10, Want: 0},
+		{Name: "backward", Prev: 10, Curr: 5, Want: 0},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			p, o := Packet{Sequence: d.Curr}, Packet{Sequence: d.Prev}
+			if got := p.Missing(&o); got != d.Want {
+				t.Errorf("want %d missing, got %d", d.Want, got)
+			}
+		})
+	}
+}
+
+func TestExtractorReleased(t *testing.T) {
+	decode := func(seq uint32, pointer uint16, payload []byte) *Cadu {
+		c := Cadu{Header: &Header{Word: 0x1acffc1d, Version: 1, Space: 0x23, Channel: 1, Sequence: seq, Data: pointer}, Payload: payload}
+		seal(DefaultProfile, &c)
+		x, err := DefaultProfile.Decode(bytes.NewReader(DefaultProfile.Encode(&c)))
+		if err != nil {
+			t.Fatalf("unexpected error decoding cadu: %s", err)
+		}
+		return x
+	}
+	// a space packet longer than a cadu
+	body := make([]byte, DefaultProfile.BodyLen())
+	body[0], body[4], body[5] = 0x08, 0x10, 0x00
+
+	e := NewExtractor()
+	c := decode(1, 0, body)
+	e.Extract(c)
+	c.Release()
+
+	c = decode(5, PointerNoStart, make([]byte, DefaultProfile.BodyLen()))
+	defer c.Release()
+	if ps := e.Extract(c); len(ps) != 0 {
+		t.Fatalf("unexpected packets extracted after a gap: %d", len(ps))
+	}
+	if e.Dropped != 1 {
+		t.Errorf("partial packet not dropped after the gap")
+	}
+}
@@ -0,0 +1,85 @@
+package cadus
+
+type (
+	caduStage  func(*Cadu) (*Cadu, bool)
+	frameStage func([]byte) ([]byte, bool)
+)
+
+type Pipeline struct {
+	cadus  []caduStage
+	frames []frameStage
+}
+
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+func (p *Pipeline) Len() int {
+	return len(p.cadus) + len(p.frames)
+}
+
+func (p *Pipeline) Filter(f func(*Cadu) bool) *Pipeline {
+	p.cadus = append(p.cadus, func(c *Cadu) (*Cadu, bool) {
+		return c, f(c)
+	})
+	return p
+}
+
+func (p *Pipeline) Transform(f func(*Cadu) *Cadu) *Pipeline {
+	p.cadus = append(p.cadus, func(c *Cadu) (*Cadu, bool) {
+		c = f(c)
+		return c, c != nil
+	})
+	return p
+}
+
+func (p *Pipeline) Observe(f func(*Cadu)) *Pipeline {
+	p.cadus = append(p.cadus, func(c *Cadu) (*Cadu, bool) {
+		f(c)
+		return c, true
+	})
+	return p
+}
+
+func (p *Pipeline) FilterFrame(f func([]byte) bool) *Pipeline {
+	p.frames = append(p.frames, func(bs []byte) ([]byte, bool) {
+		return bs, f(bs)
+	})
+	return p
+}
+
+func (p *Pipeline) TransformFrame(f func([]byte) []byte) *Pipeline {
+	p.frames = append(p.frames, func(bs []byte) ([]byte, bool) {
+		bs = f(bs)
+		return bs, bs != nil
+	})
+	return p
+}
+
+func (p *Pipeline) ObserveFrame(f func([]byte)) *Pipeline {
+	p.frames = append(p.frames, func(bs []byte) ([]byte, bool) {
+		f(bs)
+		return bs, true
+	})
+	return p
+}
+
+func (p *Pipeline) Cadu(c *Cadu) (*Cadu, bool) {
+	for _, s := range p.cadus {
+		var ok bool
+		if c, ok = s(c); !ok {
+			return nil, false
+		}
+	}
+	return c, true
+}
+
+func (p *Pipeline) Frame(bs []byte) ([]byte, bool) {
+	for _, s := range p.frames {
+		var ok bool
+		if bs, ok = s(bs); !ok {
+			return nil, false
+		}
+	}
+	return bs, true
+}
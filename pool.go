@@ -0,0 +1,64 @@
+package cadus
+
+import "sync"
+
+type caduBuffer struct {
+	cadu    Cadu
+	header  Header
+	payload []byte
+}
+
+var caduPool = sync.Pool{
+	New: func() interface{} {
+		return new(caduBuffer)
+	},
+}
+
+func (p Profile) alloc(n int) *Cadu {
+	b := caduPool.Get().(*caduBuffer)
+	if cap(b.payload) < n {
+		b.payload = make([]byte, n)
+	}
+	b.header = Header{}
+	b.cadu = Cadu{
+		Header:  &b.header,
+		Payload: b.payload[:n],
+		profile: p,
+		buffer:  b,
+	}
+	return &b.cadu
+}
+
+// Release hands the header and payload of a decoded cadu back to the decoders
+// for reuse. Neither c nor any of its fields may be used after it has been
+// released: callers that need to keep a cadu around should Clone it first.
+func (c *Cadu) Release() {
+	b := c.buffer
+	if b == nil {
+		return
+	}
+	c.buffer = nil
+	caduPool.Put(b)
+}
+
+// Clone returns a deep copy of c that is not affected by Release.
+func (c *Cadu) Clone() *Cadu {
+	x := *c
+	x.buffer = nil
+	if c.Header != nil {
+		h := *c.Header
+		x.Header = &h
+	}
+	x.Insert = clone(c.Insert)
+	x.Secondary = clone(c.Secondary)
+	x.Payload = clone(c.Payload)
+	x.Check = clone(c.Check)
+	return &x
+}
+
+func clone(bs []byte) []byte {
+	if bs == nil {
+		return nil
+	}
+	return append([]byte(nil), bs...)
+}
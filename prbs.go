@@ -0,0 +1,46 @@
+package cadus
+
+import (
+	"encoding/binary"
+)
+
+const PRBSTag = 0x50524253
+
+type PRBS struct {
+	counter uint32
+	buffer  []byte
+}
+
+func NewPRBS() *PRBS {
+	return &PRBS{}
+}
+
+func (p *PRBS) Read(bs []byte) (int, error) {
+	if len(p.buffer) == 0 {
+		vs := make([]byte, CaduBodyLen)
+		binary.BigEndian.PutUint32(vs, PRBSTag)
+		binary.BigEndian.PutUint32(vs[4:], p.counter)
+		PRBS23(p.counter, vs[8:])
+		p.buffer = vs
+		p.counter++
+	}
+	n := copy(bs, p.buffer)
+	p.buffer = p.buffer[n:]
+	return n, nil
+}
+
+func PRBS23(seed uint32, bs []byte) {
+	state := seed & 0x7FFFFF
+	if state == 0 {
+		state = 1
+	}
+	for i := range bs {
+		var b byte
+		for j := 0; j < 8; j++ {
+			bit := ((state >> 22) ^ (state >> 17)) & 1
+			state = ((state << 1) | bit) & 0x7FFFFF
+			b = b<<1 | byte(bit)
+		}
+		bs[i] = b
+	}
+}
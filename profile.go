@@ -0,0 +1,372 @@
+package cadus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	FHECLen = 2
+	MPDULen = 2
+	OCFLen  = 4
+)
+
+type FHECError struct {
+	Want uint16
+	Got  uint16
+}
+
+func (f FHECError) Error() string {
+	return fmt.Sprintf("invalid fhec: want %04x, got %04x", f.Want, f.Got)
+}
+
+type CorruptedError struct {
+	Reason interface{}
+}
+
+func (c CorruptedError) Error() string {
+	return fmt.Sprintf("corrupted input: %v", c.Reason)
+}
+
+const (
+	FrameAOS = "aos"
+	FrameTM  = "tm"
+)
+
+type Profile struct {
+	Frame  string
+	Length int
+	FHEC   bool
+	Check  bool
+	Insert int
+	OCF    bool
+	FECF   bool
+	Head   int
+	Tail   int
+
+	Randomized bool
+	RS         int
+	Tolerant   bool
+}
+
+func (p Profile) Size() int {
+	return p.Head + p.Length + p.Tail
+}
+
+var DefaultProfile = Profile{Frame: FrameAOS, Length: CaduLen, FHEC: true, FECF: true}
+
+func (p Profile) HeaderLen() int {
+	if p.Frame == FrameTM {
+		return len(CaduMagic) + TMHeaderLen
+	}
+	z := len(CaduMagic) + 6 + p.Insert + MPDULen
+	if p.FHEC {
+		z += FHECLen
+	}
+	return z
+}
+
+func (p Profile) TrailerLen() int {
+	var z int
+	if p.OCF {
+		z += OCFLen
+	}
+	if p.FECF {
+		z += CaduCRCLen
+	}
+	return z + p.RS*RSBlockLen
+}
+
+func (p Profile) BodyLen() int {
+	return p.Length - p.HeaderLen() - p.TrailerLen()
+}
+
+func (p Profile) Validate() error {
+	switch p.Frame {
+	case FrameAOS:
+	case FrameTM:
+		if p.FHEC || p.Insert > 0 {
+			return fmt.Errorf("tm frames have no fhec nor insert zone")
+		}
+	default:
+		return fmt.Errorf("%s: unsupported frame type", p.Frame)
+	}
+	if p.Check && !p.FHEC {
+		return fmt.Errorf("fhec check requires the fhec field")
+	}
+	if p.RS < 0 || p.RS > 8 {
+		return fmt.Errorf("invalid profile: unsupported interleaving depth %d", p.RS)
+	}
+	if p.Head < 0 || p.Tail < 0 {
+		return fmt.Errorf("invalid profile: negative number of bytes skipped")
+	}
+	if p.Insert < 0 || p.BodyLen() <= 0 {
+		return fmt.Errorf("invalid profile: %d bytes frame with %d bytes header", p.Length, p.HeaderLen())
+	}
+	return nil
+}
+
+func (p Profile) CounterMask() uint32 {
+	if p.Frame == FrameTM {
+		return 0xFF
+	}
+	return MaxSequenceCounter - 1
+}
+
+func (p Profile) Decode(r io.Reader) (*Cadu, error) {
+	if p.Tolerant {
+		if err := p.bounds(); err != nil {
+			return nil, err
+		}
+	}
+	if p.Head > 0 {
+		if _, err := io.CopyN(ioutil.Discard, r, int64(p.Head)); err != nil {
+			return nil, err
+		}
+	}
+	if p.Randomized {
+		bs := make([]byte, p.Length)
+		if _, err := io.ReadFull(r, bs); err != nil {
+			return nil, err
+		}
+		Randomize(bs[len(CaduMagic):])
+		c, err := p.decode(bytes.NewReader(bs))
+		if err != nil {
+			return nil, err
+		}
+		return c, p.skipTail(r)
+	}
+	c, err := p.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return c, p.skipTail(r)
+}
+
+// bounds reports the lengths of p that do not fit in a frame.
+func (p Profile) bounds() error {
+	switch {
+	case p.Head < 0 || p.Tail < 0:
+		return CorruptedError{Reason: fmt.Sprintf("negative number of bytes skipped (%d, %d)", p.Head, p.Tail)}
+	case p.Insert < 0:
+		return CorruptedError{Reason: fmt.Sprintf("negative insert zone length (%d)", p.Insert)}
+	case p.RS < 0:
+		return CorruptedError{Reason: fmt.Sprintf("negative interleaving depth (%d)", p.RS)}
+	case p.BodyLen() < 0:
+		return CorruptedError{Reason: fmt.Sprintf("%d bytes header and %d bytes trailer longer than %d bytes frame", p.HeaderLen(), p.TrailerLen(), p.Length)}
+	}
+	return nil
+}
+
+func (p Profile) skipTail(r io.Reader) error {
+	if p.Tail > 0 {
+		if _, err := io.CopyN(ioutil.Discard, r, int64(p.Tail)); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Profile) decode(r io.Reader) (*Cadu, error) {
+	if p.Frame == FrameTM {
+		return p.decodeTM(r)
+	}
+	var (
+		c   = p.alloc(p.BodyLen())
+		h   = c.Header
+		buf [CaduHeaderLen]byte
+	)
+	if _, err := io.ReadFull(r, buf[:len(CaduMagic)]); err != nil {
+		c.Release()
+		return nil, err
+	}
+	h.Word = binary.BigEndian.Uint32(buf[:])
+
+	sum := SumCCITT()
+	rs := io.TeeReader(r, sum)
+
+	vs := buf[:6]
+	if p.FHEC {
+		vs = buf[:6+FHECLen]
+	}
+	if _, err := io.ReadFull(rs, vs); err != nil {
+		c.Release()
+		return nil, err
+	}
+	pid := binary.BigEndian.Uint16(vs)
+	h.Version = uint8((pid & 0xC000) >> 14)
+	h.Space = (pid & 0x3FC0) >> 6
+	h.Channel = uint8(pid & 0x003F)
+
+	seq := binary.BigEndian.Uint32(vs[2:])
+	h.Sequence = seq >> 8
+	h.Signaling = uint8(seq)
+	h.Replay = (seq>>7)&1 == 1
+
+	if p.FHEC {
+		h.Control = binary.BigEndian.Uint16(vs[6:])
+	}
+	if p.Insert > 0 {
+		c.Insert = make([]byte, p.Insert)
+		if _, err := io.ReadFull(rs, c.Insert); err != nil {
+			c.Release()
+			return nil, err
+		}
+	}
+	if _, err := io.ReadFull(rs, buf[:MPDULen]); err != nil {
+		c.Release()
+		return nil, err
+	}
+	h.Data = binary.BigEndian.Uint16(buf[:])
+
+	if _, err := io.ReadFull(rs, c.Payload); err != nil {
+		c.Release()
+		return nil, err
+	}
+	if err := c.readOCF(rs); err != nil {
+		c.Release()
+		return nil, err
+	}
+	if err := c.readFECF(r, sum); err != nil {
+		c.Release()
+		return nil, err
+	}
+	if err := c.readRS(r); err != nil {
+		c.Release()
+		return nil, err
+	}
+	if want := FHEC(pid, h.Signaling); c.Error == nil && p.Check && want != h.Control {
+		c.Error = FHECError{Want: want, Got: h.Control}
+	}
+	return c, nil
+}
+
+func (c *Cadu) readOCF(r io.Reader) error {
+	if !c.profile.OCF {
+		return nil
+	}
+	var buf [OCFLen]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return err
+	}
+	c.OCF = binary.BigEndian.Uint32(buf[:])
+	return nil
+}
+
+func (c *Cadu) readRS(r io.Reader) error {
+	if c.profile.RS == 0 {
+		return nil
+	}
+	c.Check = make([]byte, c.profile.RS*RSBlockLen)
+	_, err := io.ReadFull(r, c.Check)
+	return err
+}
+
+func (c *Cadu) readFECF(r io.Reader, sum hash.Hash32) error {
+	if !c.profile.FECF {
+		return nil
+	}
+	var buf [CaduCRCLen]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return err
+	}
+	c.Control = binary.BigEndian.Uint16(buf[:])
+	if s := sum.Sum32(); uint16(s) != c.Control {
+		c.Error = ChecksumError{Want: c.Control, Got: uint16(s)}
+	}
+	return nil
+}
+
+func (p Profile) Encode(c *Cadu) []byte {
+	bs := p.encode(c)
+	if p.Randomized {
+		Randomize(bs[len(CaduMagic):])
+	}
+	return bs
+}
+
+func (p Profile) encode(c *Cadu) []byte {
+	if p.Frame == FrameTM {
+		return p.encodeTM(c)
+	}
+	var body bytes.Buffer
+
+	pid := uint16(c.Version)<<14 | uint16(c.Space)<<6 | uint16(c.Channel)
+	fragment := (c.Sequence%MaxSequenceCounter)<<8 | uint32(c.Signaling)
+
+	binary.Write(&body, binary.BigEndian, c.Word)
+	binary.Write(&body, binary.BigEndian, pid)
+	binary.Write(&body, binary.BigEndian, fragment)
+	if p.FHEC {
+		binary.Write(&body, binary.BigEndian, c.Header.Control)
+	}
+	body.Write(c.Insert)
+	binary.Write(&body, binary.BigEndian, c.Data)
+	body.Write(c.Payload)
+	p.writeTrailer(&body, c)
+
+	return body.Bytes()
+}
+
+func (p Profile) writeTrailer(body *bytes.Buffer, c *Cadu) {
+	if p.OCF {
+		binary.Write(body, binary.BigEndian, c.OCF)
+	}
+	if p.FECF {
+		binary.Write(body, binary.BigEndian, c.Control)
+	}
+	body.Write(c.Check)
+}
+
+var (
+	gfExp [30]byte
+	gfLog [16]byte
+	fhecG [4]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 15; i++ {
+		gfExp[i], gfExp[i+15] = x, x
+		gfLog[x] = byte(i)
+		if x <<= 1; x&0x10 != 0 {
+			x ^= 0x13
+		}
+	}
+	g := []byte{1}
+	for i := 6; i <= 9; i++ {
+		n := make([]byte, len(g)+1)
+		for j, v := range g {
+			n[j] ^= v
+			n[j+1] ^= gfMul(v, gfExp[i])
+		}
+		g = n
+	}
+	copy(fhecG[:], g[1:])
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func FHEC(pid uint16, signaling uint8) uint16 {
+	info := uint32(pid)<<8 | uint32(signaling)
+
+	var parity [4]byte
+	for i := 5; i >= 0; i-- {
+		f := byte(info>>(uint(i)*4)&0xF) ^ parity[0]
+		copy(parity[:], parity[1:])
+		parity[3] = 0
+		for j := range parity {
+			parity[j] ^= gfMul(f, fhecG[j])
+		}
+	}
+	return uint16(parity[0])<<12 | uint16(parity[1])<<8 | uint16(parity[2])<<4 | uint16(parity[3])
+}
@@ -0,0 +1,219 @@
+package cadus
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// seal sets the fhec and the fecf of c as the encoder of p would.
+func seal(p Profile, c *Cadu) {
+	if p.FHEC {
+		pid := uint16(c.Version)<<14 | uint16(c.Space)<<6 | uint16(c.Channel)
+		c.Header.Control = FHEC(pid, c.Signaling)
+	}
+	if p.FECF {
+		bs := p.encode(c)
+		c.Control = CalculateCRC(bs[len(CaduMagic) : len(bs)-len(c.Check)-CaduCRCLen])
+	}
+}
+
+func TestProfileRoundTrip(t *testing.T) {
+	word := uint32(0x1acffc1d)
+	data := []struct {
+		Name    string
+		Profile Profile
+		Cadu    Cadu
+	}{
+		{
+			Name:    "aos",
+			Profile: DefaultProfile,
+			Cadu:    Cadu{Header: &Header{Word: word, Version: 1, Space: 0x23, Channel: 5, Sequence: 0xABCDEF, Signaling: 0x80, Replay: true, Data: 0x0123}},
+		},
+		{
+			Name:    "aos+insert+ocf",
+			Profile: Profile{Frame: FrameAOS, Length: CaduLen, FHEC: true, Check: true, Insert: 6, OCF: true, FECF: true},
+			Cadu:    Cadu{Header: &Header{Word: word, Version: 1, Space: 0x23, Channel: 1, Sequence: 7, Data: PointerNoStart}, Insert: []byte("insert"), OCF: 0xDEADBEEF},
+		},
+		{
+			Name:    "aos+randomized+rs",
+			Profile: Profile{Frame: FrameAOS, Length: CaduLen, FHEC: true, FECF: true, Randomized: true, RS: 2},
+			Cadu:    Cadu{Header: &Header{Word: word, Version: 1, Space: 0x23, Channel: 63, Sequence: 1, Data: PointerIdle}, Check: bytes.Repeat([]byte{0xA5}, 2*RSBlockLen)},
+		},
+		{
+			Name:    "tm",
+			Profile: Profile{Frame: FrameTM, Length: 1115, FECF: true},
+			Cadu:    Cadu{Header: &Header{Word: word, Space: 0x2A5, Channel: 3, Master: 9, Sequence: 200, Data: 0x1800}},
+		},
+		{
+			Name:    "tm+secondary+ocf",
+			Profile: Profile{Frame: FrameTM, Length: 1115, OCF: true, FECF: true},
+			Cadu:    Cadu{Header: &Header{Word: word, Space: 1, Channel: 7, Master: 255, Sequence: 255, Data: 0x9800}, Secondary: []byte{0x03, 1, 2, 3}, OCF: 0x01020304},
+		},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			want := d.Cadu
+			want.Payload = make([]byte, d.Profile.BodyLen()-len(want.Secondary))
+			for i := range want.Payload {
+				want.Payload[i] = byte(i)
+			}
+			seal(d.Profile, &want)
+
+			got, err := d.Profile.Decode(bytes.NewReader(d.Profile.Encode(&want)))
+			if err != nil {
+				t.Fatalf("unexpected error decoding cadu: %s", err)
+			}
+			defer got.Release()
+			if got.Error != nil {
+				t.Fatalf("unexpected error in cadu: %s", got.Error)
+			}
+			if !reflect.DeepEqual(*got.Header, *want.Header) {
+				t.Errorf("headers mismatched:\nwant %+v\ngot  %+v", *want.Header, *got.Header)
+			}
+			if !bytes.Equal(got.Payload, want.Payload) {
+				t.Errorf("payloads mismatched")
+			}
+			if !bytes.Equal(got.Insert, want.Insert) || !bytes.Equal(got.Secondary, want.Secondary) || !bytes.Equal(got.Check, want.Check) {
+				t.Errorf("optional fields mismatched: want %x/%x/%x, got %x/%x/%x", want.Insert, want.Secondary, want.Check, got.Insert, got.Secondary, got.Check)
+			}
+			if got.OCF != want.OCF || got.Control != want.Control {
+				t.Errorf("trailers mismatched: want %08x/%04x, got %08x/%04x", want.OCF, want.Control, got.OCF, got.Control)
+			}
+		})
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	c := Cadu{Header: &Header{Word: 0x1acffc1d, Version: 1, Space: 0x23, Channel: 1}, Payload: IdlePayload}
+	p := Profile{Frame: FrameAOS, Length: CaduLen, FHEC: true, Check: true, FECF: true}
+	seal(p, &c)
+
+	data := []struct {
+		Name   string
+		Offset int
+		Want   interface{}
+	}{
+		{Name: "fecf", Offset: 100, Want: ChecksumError{}},
+		{Name: "fhec", Offset: 5, Want: FHECError{}},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			bs := p.Encode(&c)
+			bs[d.Offset] ^= 0x01
+			if d.Name == "fhec" {
+				// keep the fecf of the corrupted header valid
+				sum := CalculateCRC(bs[len(CaduMagic) : len(bs)-CaduCRCLen])
+				bs[len(bs)-2], bs[len(bs)-1] = byte(sum>>8), byte(sum)
+			}
+			got, err := p.Decode(bytes.NewReader(bs))
+			if err != nil {
+				t.Fatalf("unexpected error decoding cadu: %s", err)
+			}
+			defer got.Release()
+			if reflect.TypeOf(got.Error) != reflect.TypeOf(d.Want) {
+				t.Errorf("unexpected error: want %T, got %v", d.Want, got.Error)
+			}
+		})
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	data := []struct {
+		Name    string
+		Profile Profile
+		Valid   bool
+	}{
+		{Name: "aos", Profile: DefaultProfile, Valid: true},
+		{Name: "aos+check", Profile: Profile{Frame: FrameAOS, Length: CaduLen, FHEC: true, Check: true}, Valid: true},
+		{Name: "aos+check-fhec", Profile: Profile{Frame: FrameAOS, Length: CaduLen, Check: true}},
+		{Name: "tm", Profile: Profile{Frame: FrameTM, Length: 1115, FECF: true}, Valid: true},
+		{Name: "tm+fhec", Profile: Profile{Frame: FrameTM, Length: 1115, FHEC: true}},
+		{Name: "tm+insert", Profile: Profile{Frame: FrameTM, Length: 1115, Insert: 4}},
+		{Name: "rs", Profile: Profile{Frame: FrameAOS, Length: CaduLen, RS: 9}},
+		{Name: "short", Profile: Profile{Frame: FrameAOS, Length: 12}},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			if err := d.Profile.Validate(); (err == nil) != d.Valid {
+				t.Errorf("unexpected validation error: %v", err)
+			}
+		})
+	}
+	if _, err := NewProfile(WithFrame(FrameTM), WithLength(1115)); err != nil {
+		t.Errorf("unexpected error with tm frames: %s", err)
+	}
+}
+
+func TestProfileTolerant(t *testing.T) {
+	data := []struct {
+		Name    string
+		Profile Profile
+	}{
+		{Name: "short", Profile: Profile{Frame: FrameAOS, Length: 10, Randomized: true}},
+		{Name: "insert", Profile: Profile{Frame: FrameAOS, Length: CaduLen, Insert: -1}},
+		{Name: "rs", Profile: Profile{Frame: FrameAOS, Length: CaduLen, RS: -1}},
+		{Name: "tm", Profile: Profile{Frame: FrameTM, Length: 8, FECF: true}},
+	}
+	input := make([]byte, 2*CaduLen)
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			d.Profile.Tolerant = true
+			_, err := d.Profile.Decode(bytes.NewReader(input))
+			if _, ok := err.(CorruptedError); !ok {
+				t.Errorf("want corrupted input error, got %v", err)
+			}
+		})
+	}
+}
+
+// fuzzProfile derives from opts the variant of the default profile decoding
+// the input of FuzzDecode.
+func fuzzProfile(opts byte) Profile {
+	p := DefaultProfile
+	if opts&0x01 != 0 {
+		p.Frame, p.FHEC = FrameTM, false
+	}
+	p.OCF = opts&0x02 != 0
+	p.FECF = opts&0x04 != 0
+	p.Randomized = opts&0x08 != 0
+	p.RS = int(opts>>4) & 0x03
+	if opts&0x40 != 0 {
+		p.Head, p.Tail = 8, 4
+	}
+	p.Check = p.FHEC && opts&0x80 != 0
+	return p
+}
+
+func FuzzDecode(f *testing.F) {
+	var (
+		word = uint32(0x1acffc1d)
+		aos  = &Cadu{Header: &Header{Word: word, Space: 0x23, Channel: 1, Sequence: 42}, Payload: IdlePayload}
+		tm   = Profile{Frame: FrameTM, Length: CaduLen, FECF: true}
+	)
+	f.Add(byte(0), DefaultProfile.Encode(aos))
+	f.Add(byte(0x05), tm.Encode(&Cadu{Header: &Header{Word: word}, Payload: make([]byte, tm.BodyLen())}))
+	f.Add(byte(0x0c), Profile{Frame: FrameAOS, Length: CaduLen, FHEC: true, FECF: true, Randomized: true}.Encode(aos))
+	f.Add(byte(0x40), append(make([]byte, 8), DefaultProfile.Encode(aos)...))
+	f.Add(byte(0), []byte{})
+
+	f.Fuzz(func(t *testing.T, opts byte, data []byte) {
+		p := fuzzProfile(opts)
+		if p.Validate() != nil {
+			return
+		}
+		c, err := p.Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		defer c.Release()
+
+		if len(data) < p.Head+p.Length {
+			t.Fatalf("cadu decoded from %d bytes (want %d)", len(data), p.Head+p.Length)
+		}
+		want := data[p.Head : p.Head+p.Length]
+		if got := c.Profile().Encode(c); !bytes.Equal(got, want) {
+			t.Fatalf("cadu not encoded back to its input:\nwant %x\ngot  %x", want, got)
+		}
+	})
+}
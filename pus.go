@@ -0,0 +1,68 @@
+package cadus
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	PUSA = 1
+	PUSC = 2
+)
+
+type PUS struct {
+	Version     uint8
+	Service     uint8
+	Subservice  uint8
+	Counter     uint16
+	Destination uint16
+	Coarse      uint32
+	Fine        uint16
+}
+
+func DecodePUS(p *Packet, coarse, fine int) (*PUS, error) {
+	if p.Encapsulated() || !p.Secondary || p.Type != 0 {
+		return nil, fmt.Errorf("%s: no pus tm secondary header", p)
+	}
+	if coarse < 1 || coarse > 4 || fine < 0 || fine > 3 {
+		return nil, fmt.Errorf("unsupported cuc format %d.%d", coarse, fine)
+	}
+	bs := p.Data[p.Header:]
+	if len(bs) == 0 {
+		return nil, fmt.Errorf("%s: empty secondary header", p)
+	}
+	var (
+		h      PUS
+		offset int
+	)
+	switch h.Version = bs[0] >> 4; h.Version {
+	case PUSC:
+		offset = 7
+	default:
+		h.Version = (bs[0] >> 4) & 0x07
+		offset = 3
+	}
+	if len(bs) < offset+coarse+fine {
+		return nil, fmt.Errorf("%s: secondary header too short", p)
+	}
+	h.Service, h.Subservice = bs[1], bs[2]
+	if h.Version == PUSC {
+		h.Counter = binary.BigEndian.Uint16(bs[3:])
+		h.Destination = binary.BigEndian.Uint16(bs[5:])
+	}
+	for _, b := range bs[offset : offset+coarse] {
+		h.Coarse = h.Coarse<<8 | uint32(b)
+	}
+	var f uint32
+	for _, b := range bs[offset+coarse : offset+coarse+fine] {
+		f = f<<8 | uint32(b)
+	}
+	switch fine {
+	case 1:
+		f <<= 8
+	case 3:
+		f >>= 8
+	}
+	h.Fine = uint16(f)
+	return &h, nil
+}
@@ -0,0 +1,24 @@
+package cadus
+
+const RSBlockLen = 32
+
+var pseudoRandom [255]byte
+
+func init() {
+	s := byte(0xFF)
+	for i := range pseudoRandom {
+		var b byte
+		for j := 0; j < 8; j++ {
+			b = b<<1 | s>>7
+			fb := (s>>7 ^ s>>4 ^ s>>2 ^ s) & 1
+			s = s<<1 | fb
+		}
+		pseudoRandom[i] = b
+	}
+}
+
+func Randomize(bs []byte) {
+	for i := range bs {
+		bs[i] ^= pseudoRandom[i%len(pseudoRandom)]
+	}
+}
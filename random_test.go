@@ -0,0 +1,24 @@
+package cadus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRandomize(t *testing.T) {
+	var (
+		head = []byte{0xFF, 0x48, 0x0E, 0xC0, 0x9A, 0x0D, 0x70, 0xBC}
+		bs   = make([]byte, 2*len(pseudoRandom)+10)
+	)
+	Randomize(bs)
+	if !bytes.Equal(bs[:len(head)], head) {
+		t.Errorf("sequence mismatched: want %x, got %x", head, bs[:len(head)])
+	}
+	if !bytes.Equal(bs[len(pseudoRandom):2*len(pseudoRandom)], bs[:len(pseudoRandom)]) {
+		t.Errorf("sequence does not repeat after %d bytes", len(pseudoRandom))
+	}
+	Randomize(bs)
+	if !bytes.Equal(bs, make([]byte, len(bs))) {
+		t.Errorf("randomizing twice does not give back the input")
+	}
+}
@@ -0,0 +1,134 @@
+package cadus
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	CCITT = uint16(0xFFFF)
+	POLY  = uint16(0x1021)
+)
+
+type ccittSum struct {
+	sum uint16
+}
+
+func SumCCITT() hash.Hash32 {
+	return &ccittSum{sum: CCITT}
+}
+
+func (c *ccittSum) Size() int      { return 2 }
+func (c *ccittSum) BlockSize() int { return 32 }
+func (c *ccittSum) Reset()         { c.sum = CCITT }
+
+var ccittTable [8][256]uint16
+
+func init() {
+	for i := range ccittTable[0] {
+		sum := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if sum&0x8000 > 0 {
+				sum = (sum << 1) ^ POLY
+			} else {
+				sum <<= 1
+			}
+		}
+		ccittTable[0][i] = sum
+	}
+	for i := range ccittTable[0] {
+		for j := 1; j < len(ccittTable); j++ {
+			prev := ccittTable[j-1][i]
+			ccittTable[j][i] = (prev << 8) ^ ccittTable[0][prev>>8]
+		}
+	}
+}
+
+func (c *ccittSum) Write(bs []byte) (int, error) {
+	sum, t := c.sum, &ccittTable
+	vs := bs
+	for ; len(vs) >= 8; vs = vs[8:] {
+		sum = t[7][vs[0]^byte(sum>>8)] ^ t[6][vs[1]^byte(sum)] ^
+			t[5][vs[2]] ^ t[4][vs[3]] ^ t[3][vs[4]] ^ t[2][vs[5]] ^ t[1][vs[6]] ^ t[0][vs[7]]
+	}
+	for _, b := range vs {
+		sum = (sum << 8) ^ t[0][byte(sum>>8)^b]
+	}
+	c.sum = sum
+	return len(bs), nil
+}
+
+func (c *ccittSum) Sum(bs []byte) []byte {
+	c.Write(bs)
+
+	vs := make([]byte, 4)
+	binary.BigEndian.PutUint32(vs, c.Sum32())
+	return vs
+}
+
+func (c *ccittSum) Sum32() uint32 {
+	return uint32(c.sum)
+}
+
+func CalculateCRC(bs []byte) uint16 {
+	s := SumCCITT()
+	s.Write(bs)
+	return uint16(s.Sum32())
+}
+
+type vmuSum struct {
+	sum uint32
+}
+
+func SumVMU() hash.Hash32 {
+	return &vmuSum{}
+}
+
+func (v *vmuSum) Size() int      { return 4 }
+func (v *vmuSum) BlockSize() int { return 32 }
+func (v *vmuSum) Reset()         { v.sum = 0 }
+
+func (v *vmuSum) Sum(bs []byte) []byte {
+	v.Write(bs)
+	vs := make([]byte, v.Size())
+	binary.LittleEndian.PutUint32(vs, v.sum)
+
+	return vs
+}
+
+func (v *vmuSum) Write(bs []byte) (int, error) {
+	v.sum += byteSum(bs)
+	return len(bs), nil
+}
+
+// byteSum adds the bytes eight at a time in the 16 bits lanes of an uint64,
+// folding the lanes back before they can overflow.
+func byteSum(bs []byte) uint32 {
+	const (
+		lanes = 0x00FF00FF00FF00FF
+		fold  = 128
+	)
+	var sum uint32
+	for len(bs) >= 8 {
+		n := len(bs) / 8
+		if n > fold {
+			n = fold
+		}
+		var acc uint64
+		for i := 0; i < n; i++ {
+			w := binary.LittleEndian.Uint64(bs[i*8:])
+			acc += w&lanes + (w>>8)&lanes
+		}
+		acc = acc&0x0000FFFF0000FFFF + (acc>>16)&0x0000FFFF0000FFFF
+		sum += uint32(acc) + uint32(acc>>32)
+		bs = bs[n*8:]
+	}
+	for _, b := range bs {
+		sum += uint32(b)
+	}
+	return sum
+}
+
+func (v *vmuSum) Sum32() uint32 {
+	return v.sum
+}
@@ -0,0 +1,57 @@
+package cadus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCalculateCRC(t *testing.T) {
+	data := []struct {
+		Input []byte
+		Want  uint16
+	}{
+		{Input: nil, Want: 0xFFFF},
+		{Input: []byte("A"), Want: 0xB915},
+		{Input: []byte("123456789"), Want: 0x29B1},
+		{Input: bytes.Repeat([]byte{0xFF}, 4), Want: 0x1D0F},
+	}
+	for _, d := range data {
+		if got := CalculateCRC(d.Input); got != d.Want {
+			t.Errorf("%q: want %04x, got %04x", d.Input, d.Want, got)
+		}
+	}
+}
+
+func TestSumCCITTWrites(t *testing.T) {
+	bs := make([]byte, 1019)
+	for i := range bs {
+		bs[i] = byte(i * 7)
+	}
+	want := CalculateCRC(bs)
+	for _, n := range []int{1, 3, 8, 13, 100} {
+		s := SumCCITT()
+		for vs := bs; len(vs) > 0; {
+			z := n
+			if z > len(vs) {
+				z = len(vs)
+			}
+			s.Write(vs[:z])
+			vs = vs[z:]
+		}
+		if got := uint16(s.Sum32()); got != want {
+			t.Errorf("writes of %d bytes: want %04x, got %04x", n, want, got)
+		}
+	}
+}
+
+func TestSumVMU(t *testing.T) {
+	data := []int{0, 1, 7, 8, 9, 1024, 128*8 + 3, 4096}
+	for _, n := range data {
+		bs := bytes.Repeat([]byte{0xFF}, n)
+		s := SumVMU()
+		s.Write(bs)
+		if got, want := s.Sum32(), uint32(n*0xFF); got != want {
+			t.Errorf("%d bytes: want %d, got %d", n, want, got)
+		}
+	}
+}
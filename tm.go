@@ -0,0 +1,86 @@
+package cadus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const TMHeaderLen = 6
+
+func (p Profile) decodeTM(r io.Reader) (*Cadu, error) {
+	var (
+		h   Header
+		buf [4 + TMHeaderLen]byte
+	)
+	if _, err := io.ReadFull(r, buf[:len(CaduMagic)]); err != nil {
+		return nil, err
+	}
+	h.Word = binary.BigEndian.Uint32(buf[:])
+
+	sum := SumCCITT()
+	rs := io.TeeReader(r, sum)
+
+	vs := buf[len(CaduMagic):]
+	if _, err := io.ReadFull(rs, vs); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint16(vs)
+	h.Version = uint8(id >> 14)
+	h.Space = (id >> 4) & 0x3FF
+	h.Channel = uint8(id>>1) & 0x07
+	h.Master, h.Sequence = vs[2], uint32(vs[3])
+	h.Data = binary.BigEndian.Uint16(vs[4:])
+
+	c := Cadu{Header: &h, profile: p}
+	c.profile.OCF = id&1 == 1
+	if h.Data>>15 == 1 {
+		var b [1]byte
+		if _, err := io.ReadFull(rs, b[:]); err != nil {
+			return nil, err
+		}
+		c.Secondary = make([]byte, int(b[0]&0x3F)+1)
+		c.Secondary[0] = b[0]
+		if _, err := io.ReadFull(rs, c.Secondary[1:]); err != nil {
+			return nil, err
+		}
+	}
+	z := c.profile.BodyLen() - len(c.Secondary)
+	if z <= 0 {
+		return nil, CorruptedError{Reason: fmt.Sprintf("secondary header too long (%d bytes)", len(c.Secondary))}
+	}
+	c.Payload = make([]byte, z)
+	if _, err := io.ReadFull(rs, c.Payload); err != nil {
+		return nil, err
+	}
+	if err := c.readOCF(rs); err != nil {
+		return nil, err
+	}
+	if err := c.readFECF(r, sum); err != nil {
+		return nil, err
+	}
+	if err := c.readRS(r); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (p Profile) encodeTM(c *Cadu) []byte {
+	var body bytes.Buffer
+
+	id := uint16(c.Version)<<14 | (c.Space&0x3FF)<<4 | uint16(c.Channel&0x07)<<1
+	if p.OCF {
+		id |= 1
+	}
+	binary.Write(&body, binary.BigEndian, c.Word)
+	binary.Write(&body, binary.BigEndian, id)
+	body.WriteByte(c.Master)
+	body.WriteByte(uint8(c.Sequence))
+	binary.Write(&body, binary.BigEndian, c.Data)
+	body.Write(c.Secondary)
+	body.Write(c.Payload)
+	p.writeTrailer(&body, c)
+
+	return body.Bytes()
+}